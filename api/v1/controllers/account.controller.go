@@ -0,0 +1,364 @@
+package controllers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/audit"
+	"github.com/topboyasante/go-snip/pkg/auth"
+	"github.com/topboyasante/go-snip/pkg/config"
+	"github.com/topboyasante/go-snip/pkg/email"
+)
+
+// Change Password godoc
+//
+//	@Summary		Change Password
+//	@Description	Change the password of the signed-in user
+//	@Tags			Account
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			Credentials	body		types.ChangePasswordRequest	true	"credentials"
+//	@Success		200			{object}	types.APISuccessMessage
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		401			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/me/change-password [post]
+func ChangePassword(c *gin.Context) {
+	var body types.ChangePasswordRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	// The current password is required so a stolen access token cannot take over the account
+	if user.VerifyPassword(body.CurrentPassword) != nil {
+		c.Error(apierror.New(apierror.CodeInvalidCredentials, "invalid password"))
+		return
+	}
+
+	if !checkPasswordPolicy(c, body.NewPassword, user.Username, user.Email) {
+		return
+	}
+
+	if err := user.SetPassword(body.NewPassword); err != nil {
+		c.Error(apierror.Internal(err, "failed to hash password"))
+		return
+	}
+
+	if err := stores.Users.Save(&user); err != nil {
+		c.Error(apierror.Internal(err, "unable to update password"))
+		return
+	}
+
+	audit.Record(c, user.ID, audit.PasswordChanged, audit.TargetUser, user.ID.String())
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		SuccessMessage: "password has been changed",
+	})
+}
+
+// Change Email godoc
+//
+//	@Summary		Change Email
+//	@Description	Send a confirmation code to a new email address
+//	@Tags			Account
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			Email	body		types.ChangeEmailRequest	true	"new email"
+//	@Success		200		{object}	types.APISuccessMessage
+//	@Failure		400		{object}	types.APIErrorMessage
+//	@Failure		401		{object}	types.APIErrorMessage
+//	@Failure		500		{object}	types.APIErrorMessage
+//	@Router			/me/change-email [post]
+func ChangeEmail(c *gin.Context) {
+	var body types.ChangeEmailRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if user.VerifyPassword(body.Password) != nil {
+		c.Error(apierror.New(apierror.CodeInvalidCredentials, "invalid password"))
+		return
+	}
+
+	if !auth.IsEmailUnique(stores.Users, body.NewEmail) {
+		c.Error(apierror.New(apierror.CodeUserExists, "user with provided email exists"))
+		return
+	}
+
+	user.RequestEmailChange(body.NewEmail, auth.GenerateAuthToken())
+	if err := stores.Users.Save(&user); err != nil {
+		c.Error(apierror.Internal(err, "unable to change email"))
+		return
+	}
+
+	// The code goes to the new address, which proves that the user owns it
+	queueEmail(
+		email.ChangeEmail,
+		email.Locale(user.Locale),
+		struct {
+			Name      string
+			AuthToken int
+		}{Name: user.Username, AuthToken: user.PendingEmailToken},
+		body.NewEmail,
+	)
+
+	audit.Record(c, user.ID, audit.EmailChangeRequested, audit.TargetUser, user.ID.String())
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		SuccessMessage: "a code has been sent to your new email",
+	})
+}
+
+// Confirm Email Change godoc
+//
+//	@Summary		Confirm Email Change
+//	@Description	Confirm a new email address with the code sent to it
+//	@Tags			Account
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			Code	body		types.ConfirmEmailChangeRequest	true	"code"
+//	@Success		200		{object}	types.APISuccessMessage{data=types.MeResponse}
+//	@Failure		400		{object}	types.APIErrorMessage
+//	@Failure		401		{object}	types.APIErrorMessage
+//	@Failure		500		{object}	types.APIErrorMessage
+//	@Router			/me/confirm-email [post]
+func ConfirmEmailChange(c *gin.Context) {
+	var body types.ConfirmEmailChangeRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if user.PendingEmail == "" {
+		c.Error(apierror.New(apierror.CodeInvalidState, "no email change has been requested"))
+		return
+	}
+
+	// Return early if there have been too many failed attempts
+	accountKey := lockoutKey(user, user.Email)
+	if !checkLockout(c, accountKey, user) {
+		return
+	}
+
+	if body.AuthToken != user.PendingEmailToken {
+		c.Error(apierror.New(apierror.CodeInvalidToken, "token is invalid"))
+		return
+	}
+
+	clearFailedAttempts(c, accountKey)
+
+	// Someone may have signed up with the address since the change was requested
+	if !auth.IsEmailUnique(stores.Users, user.PendingEmail) {
+		c.Error(apierror.New(apierror.CodeUserExists, "user with provided email exists"))
+		return
+	}
+
+	user.ConfirmEmailChange()
+	if err := stores.Users.Save(&user); err != nil {
+		c.Error(apierror.Internal(err, "unable to change email"))
+		return
+	}
+
+	audit.Record(c, user.ID, audit.EmailChanged, audit.TargetUser, user.ID.String())
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		SuccessMessage: "email has been changed",
+		Data:           toMeResponse(user),
+	})
+}
+
+// Delete Account godoc
+//
+//	@Summary		Delete Account
+//	@Description	Schedule the signed-in user's account and snippets for deletion after a grace period
+//	@Tags			Account
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			Credentials	body		types.DeleteAccountRequest	true	"credentials"
+//	@Success		200			{object}	types.APISuccessMessage{data=types.MeResponse}
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		401			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/me [delete]
+func DeleteAccount(c *gin.Context) {
+	var body types.DeleteAccountRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if user.VerifyPassword(body.Password) != nil {
+		c.Error(apierror.New(apierror.CodeInvalidCredentials, "invalid password"))
+		return
+	}
+
+	if user.DeletionScheduledAt != nil {
+		c.Error(apierror.New(apierror.CodeInvalidState, "account is already scheduled for deletion"))
+		return
+	}
+
+	user.ScheduleDeletion(time.Now().Add(config.ENV.AccountDeletionGracePeriod))
+	if err := stores.Users.Save(&user); err != nil {
+		c.Error(apierror.Internal(err, "unable to delete account"))
+		return
+	}
+
+	audit.Record(c, user.ID, audit.AccountDeletion, audit.TargetUser, user.ID.String())
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		SuccessMessage: "account has been scheduled for deletion",
+		Data:           toMeResponse(user),
+	})
+}
+
+// Cancel Account Deletion godoc
+//
+//	@Summary		Cancel Account Deletion
+//	@Description	Keep an account that was scheduled for deletion
+//	@Tags			Account
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	types.APISuccessMessage{data=types.MeResponse}
+//	@Failure		400	{object}	types.APIErrorMessage
+//	@Failure		401	{object}	types.APIErrorMessage
+//	@Failure		500	{object}	types.APIErrorMessage
+//	@Router			/me/cancel-deletion [post]
+func CancelAccountDeletion(c *gin.Context) {
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if user.DeletionScheduledAt == nil {
+		c.Error(apierror.New(apierror.CodeInvalidState, "account is not scheduled for deletion"))
+		return
+	}
+
+	user.CancelDeletion()
+	if err := stores.Users.Save(&user); err != nil {
+		c.Error(apierror.Internal(err, "unable to cancel account deletion"))
+		return
+	}
+
+	audit.Record(c, user.ID, audit.AccountDeletionCancel, audit.TargetUser, user.ID.String())
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		SuccessMessage: "account deletion has been cancelled",
+		Data:           toMeResponse(user),
+	})
+}
+
+// Export Account godoc
+//
+//	@Summary		Export Account
+//	@Description	Download a zip archive with the signed-in user's profile and all of their snippets
+//	@Tags			Account
+//	@Produce		application/zip
+//	@Security		ApiKeyAuth
+//	@Success		200	{file}		file
+//	@Failure		401	{object}	types.APIErrorMessage
+//	@Failure		500	{object}	types.APIErrorMessage
+//	@Router			/me/export [get]
+func ExportAccount(c *gin.Context) {
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	// Expired snippets are still the user's until they are purged
+	snippets, _, err := stores.Snippets.List(store.SnippetQuery{UserID: user.ID, IncludeExpired: true})
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve snippets"))
+		return
+	}
+
+	audit.Record(c, user.ID, audit.AccountExported, audit.TargetUser, user.ID.String())
+
+	filename := fmt.Sprintf("go-snip-%s-%s.zip", user.Username, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	if err := writeAccountExport(c.Writer, user, snippets); err != nil {
+		// Headers are already on the wire, so all we can do is cut the download short
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// writeAccountExport writes profile.json, snippets.json and the raw code of each snippet into a zip archive.
+func writeAccountExport(w http.ResponseWriter, user models.User, snippets []models.Snippet) error {
+	zw := zip.NewWriter(w)
+
+	items := make([]types.NewSnippetResponse, 0, len(snippets))
+	for _, snippet := range snippets {
+		items = append(items, toSnippetResponse(snippet))
+	}
+
+	files := []struct {
+		name  string
+		value any
+	}{
+		{"profile.json", types.AccountExport{MeResponse: toMeResponse(user), ExportedAt: time.Now()}},
+		{"snippets.json", items},
+	}
+
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.value); err != nil {
+			return err
+		}
+	}
+
+	for _, snippet := range snippets {
+		f, err := zw.Create(fmt.Sprintf("snippets/%s.txt", snippet.ID))
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write([]byte(snippet.Code)); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
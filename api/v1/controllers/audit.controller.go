@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+)
+
+// Get My Audit Log godoc
+//
+//	@Summary		Get My Audit Log
+//	@Description	Get the security events performed by or on the signed-in user
+//	@Tags			Audit
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			page		query		int	false	"Page number"	default(1)
+//	@Param			page_size	query		int	false	"Page size"		default(20)
+//	@Success		200			{object}	types.APISuccessMessage{data=types.PaginatedResponse}
+//	@Failure		401			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/me/audit-log [get]
+func GetMyAuditLog(c *gin.Context) {
+	uID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "unauthorized request"))
+		return
+	}
+
+	listAuditEvents(c, models.AuditEventFilter{Involving: &uID})
+}
+
+// Get Audit Log godoc
+//
+//	@Summary		Get Audit Log
+//	@Description	Search the security events of every user. Admins only.
+//	@Tags			Audit
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			actor_id	query		string	false	"User who performed the action"
+//	@Param			action		query		string	false	"Action, e.g. auth.sign_in"
+//	@Param			target_type	query		string	false	"Target type: user, snippet or webhook"
+//	@Param			target_id	query		string	false	"Target ID"
+//	@Param			ip			query		string	false	"Client IP address"
+//	@Param			from		query		string	false	"Earliest time, RFC 3339"
+//	@Param			to			query		string	false	"Latest time (exclusive), RFC 3339"
+//	@Param			page		query		int		false	"Page number"	default(1)
+//	@Param			page_size	query		int		false	"Page size"		default(20)
+//	@Success		200			{object}	types.APISuccessMessage{data=types.PaginatedResponse}
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		401			{object}	types.APIErrorMessage
+//	@Failure		403			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/admin/audit-log [get]
+func GetAuditLog(c *gin.Context) {
+	var query types.AuditLogQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	filter := models.AuditEventFilter{
+		Action:     query.Action,
+		TargetType: query.TargetType,
+		TargetID:   query.TargetID,
+		IP:         query.IP,
+		From:       query.From,
+		To:         query.To,
+	}
+	if query.ActorID != "" {
+		// Already validated by the uuid binding rule
+		id := uuid.MustParse(query.ActorID)
+		filter.ActorID = &id
+	}
+
+	listAuditEvents(c, filter)
+}
+
+func listAuditEvents(c *gin.Context, filter models.AuditEventFilter) {
+	page, pageSize := getPagination(c)
+
+	events, total, err := models.ListAuditEvents(filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve audit log"))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: types.PaginatedResponse{
+			Items:    events,
+			Page:     page,
+			PageSize: pageSize,
+			Total:    total,
+		},
+	})
+}
@@ -1,20 +1,25 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/topboyasante/go-snip/api/v1/models"
-	"github.com/topboyasante/go-snip/internal/database"
+	"github.com/topboyasante/go-snip/internal/store"
 	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/audit"
 	"github.com/topboyasante/go-snip/pkg/auth"
-	"github.com/topboyasante/go-snip/pkg/config"
 	"github.com/topboyasante/go-snip/pkg/email"
-	"github.com/topboyasante/go-snip/pkg/validators"
+	"github.com/topboyasante/go-snip/pkg/lockout"
+	"github.com/topboyasante/go-snip/pkg/password"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -31,64 +36,57 @@ import (
 //	@Failure		500			{object}	types.APIErrorMessage
 //	@Router			/auth/sign-in [post]
 func SignIn(c *gin.Context) {
-	// Create a struct to hold the request body
-	var body struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+	var body types.UserLoginRequest
+
+	// Parse and validate the request body
+	if !bindJSON(c, &body) {
+		return
 	}
 
-	// Parse the request body and store it in the body struct
-	if c.Bind(&body) != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "failed to read request body",
-		})
+	// Find the user with the provided email
+	user, err := stores.Users.GetByUsername(body.Username)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.Internal(err, "could not retrieve user"))
 		return
 	}
 
-	// Validations to check for empty fields
-	if !validators.NotBlank(body.Username) || !validators.NotBlank(body.Password) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "some fields are empty",
-		})
+	// Return early if there have been too many failed attempts
+	accountKey := lockoutKey(user, body.Username)
+	if !checkLockout(c, accountKey, user) {
 		return
 	}
 
-	// Find the user with the provided email
-	user, err := models.GetUserByUsername(body.Username)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "user does not exist",
-		})
+	if user.ID == uuid.Nil {
+		c.Error(apierror.New(apierror.CodeUserNotFound, "user does not exist"))
 		return
 	}
 
 	// Return if the account has not been activated
 	if !user.IsActive {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "account is not activated",
-		})
+		c.Error(apierror.New(apierror.CodeAccountNotActivated, "account is not activated"))
 		return
 	}
 
 	err = user.VerifyPassword(body.Password)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "invalid password",
-		})
+		audit.Record(c, uuid.Nil, audit.SignInFailed, audit.TargetUser, user.ID.String())
+		c.Error(apierror.New(apierror.CodeInvalidCredentials, "invalid password"))
 		return
 	}
 
-	access_token, refesh_token, err := auth.CreateJWTTokens(user.ID)
+	clearFailedAttempts(c, accountKey)
+
+	access_token, refesh_token, err := auth.CreateJWTTokens(user.ID, user.TokenVersion)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "unable to create accessToken",
-		})
+		c.Error(apierror.Internal(err, "unable to create accessToken"))
 		return
 	}
 
 	// Generate a new Auth Token on Sign up
 	user.AuthToken = auth.GenerateAuthToken()
-	database.DB.Save(&user)
+	stores.Users.Save(&user)
+
+	audit.Record(c, user.ID, audit.SignIn, audit.TargetUser, user.ID.String())
 
 	// Response to be sent to the user
 	userRes := &types.UserResponse{
@@ -118,53 +116,31 @@ func SignIn(c *gin.Context) {
 //	@Failure		500			{object}	types.APIErrorMessage
 //	@Router			/auth/sign-up [post]
 func SignUp(c *gin.Context) {
-	// Create a struct to hold the request body
-	var body struct {
-		Username string `json:"username"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
+	var body types.UserSignUpRequest
 
-	// Parse the request body and store it in the body struct
-	if c.Bind(&body) != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "failed to read request body",
-		})
+	// Parse and validate the request body
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	// Validations to check for empty fields
-	if !validators.NotBlank(body.Username) || !validators.NotBlank(body.Email) || !validators.NotBlank(body.Password) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "some fields are empty",
-		})
+	//Check if a user exists with that email or username
+	if !auth.IsEmailUnique(stores.Users, body.Email) {
+		c.Error(apierror.New(apierror.CodeUserExists, "user with provided email exists"))
 		return
 	}
-
-	// Validations to check for a correct email
-	if !validators.Matches(body.Email, validators.EmailRX) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "invalid email",
-		})
+	if !auth.IsUsernameUnique(stores.Users, body.Username) {
+		c.Error(apierror.New(apierror.CodeUserExists, "user with provided username exists"))
 		return
 	}
 
-	//Check if a user exists with that email or username
-	if !auth.IsEmailUnique(body.Email) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{ErrorMessage: "user with provided email exists"})
-		return
-	}
-	if !auth.IsUsernameUnique(body.Username) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{ErrorMessage: "user with provided username exists"})
+	if !checkPasswordPolicy(c, body.Password, body.Username, body.Email) {
 		return
 	}
 
 	// Hash the password from the request body
 	hash, err := bcrypt.GenerateFromPassword([]byte(body.Password), 10)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "failed to hash password",
-		})
+		c.Error(apierror.Internal(err, "failed to hash password"))
 		return
 	}
 
@@ -173,6 +149,7 @@ func SignUp(c *gin.Context) {
 		Username: body.Username,
 		Email:    body.Email,
 		Password: string(hash),
+		Role:     models.RoleUser,
 	}
 
 	// set the user ID to a new UUID
@@ -184,31 +161,35 @@ func SignUp(c *gin.Context) {
 	// All new accounts are not active by default
 	user.IsActive = false
 
+	user.Locale = body.Locale
+	if user.Locale == "" {
+		user.Locale = acceptedLanguage(c)
+	}
+
+	// Insert the user in the DB
+	newUser := &user
+	if err := stores.Users.Create(newUser); err != nil {
+		c.Error(apierror.Internal(err, "failed to create user"))
+		return
+	}
+
+	audit.Record(c, newUser.ID, audit.SignUp, audit.TargetUser, newUser.ID.String())
+
 	// Send an email to the user to activate their account
-	email.SendMailWithSMTP(
-		email.EmailConfig,
-		"Activate your account",
-		"web/activate-account.html",
+	queueEmail(
+		email.ActivateAccount,
+		email.Locale(user.Locale),
 		struct {
 			Name      string
 			AuthToken int
 		}{Name: user.Username, AuthToken: user.AuthToken},
-		[]string{body.Email},
+		body.Email,
 	)
 
-	// Insert the user in the DB
-	newUser, err := user.Create()
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "failed to create user",
-		})
-		return
-	}
-
 	// Return a 200 status when everything was successful
 	c.JSON(http.StatusOK, types.APISuccessMessage{
 		SuccessMessage: "account created. please activate your account",
-		Data:           newUser,
+		Data:           toMeResponse(*newUser),
 	})
 }
 
@@ -228,58 +209,42 @@ func ActivateAccount(c *gin.Context) {
 	// Create an instance of models.User to hold the existing user data
 	var user models.User
 
-	// Create a struct to hold the request body
-	var body struct {
-		Email     string `json:"email"`
-		AuthToken int    `json:"auth_token"`
-	}
+	var body types.ActivateAccountRequest
 
-	// Parse the request body and store it in the body struct
-	if c.Bind(&body) != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "failed to read request body",
-		})
+	// Parse and validate the request body
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	// Validations to check for empty fields
-	if !validators.NotBlank(body.Email) || !validators.NotZero(body.AuthToken) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "some fields are empty",
-		})
+	// Find the user with the provided email and store the user details in the user variable
+	user, err := stores.Users.GetByEmail(body.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.Internal(err, "could not retrieve user"))
 		return
 	}
 
-	// Validations to check for a correct email
-	if !validators.Matches(body.Email, validators.EmailRX) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "invalid email",
-		})
+	// Return early if there have been too many failed attempts
+	accountKey := lockoutKey(user, body.Email)
+	if !checkLockout(c, accountKey, user) {
 		return
 	}
 
-	// Find the user with the provided email and store the user details in the user variable
-	user, err := models.GetUserByEmail(body.Email)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "user does not exist",
-		})
+	if user.ID == uuid.Nil {
+		c.Error(apierror.New(apierror.CodeUserNotFound, "user does not exist"))
 		return
 	}
 
 	// Check if the token is valid
 	if body.AuthToken != user.AuthToken {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "token is invalid",
-		})
+		c.Error(apierror.New(apierror.CodeInvalidToken, "token is invalid"))
 		return
 	}
 
+	clearFailedAttempts(c, accountKey)
+
 	// Return if the account has already been activated, and activate the user account if it has not
 	if user.IsActive {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "account has already been activated",
-		})
+		c.Error(apierror.New(apierror.CodeInvalidState, "account has already been activated"))
 		return
 	}
 
@@ -288,7 +253,12 @@ func ActivateAccount(c *gin.Context) {
 
 	// Generate a new auth token on account activation
 	user.AuthToken = auth.GenerateAuthToken()
-	database.DB.Save(&user)
+	if err := stores.Users.Save(&user); err != nil {
+		c.Error(apierror.Internal(err, "unable to activate account"))
+		return
+	}
+
+	audit.Record(c, user.ID, audit.AccountActivated, audit.TargetUser, user.ID.String())
 
 	c.JSON(http.StatusOK, types.APISuccessMessage{
 		SuccessMessage: "account has been activated",
@@ -310,57 +280,38 @@ func ActivateAccount(c *gin.Context) {
 func ForgotPassword(c *gin.Context) {
 	var user models.User
 
-	// Create a struct to hold the request body
-	var body struct {
-		Email string `json:"email"`
-	}
-
-	// Parse the request body and store it in the body struct
-	if c.Bind(&body) != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "failed to read request body",
-		})
-		return
-	}
-
-	// Validations to check for empty fields
-	if !validators.NotBlank(body.Email) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "the email field is empty",
-		})
-		return
-	}
+	var body types.ForgotPasswordRequest
 
-	// Validations to check for a correct email
-	if !validators.Matches(body.Email, validators.EmailRX) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "invalid email",
-		})
+	// Parse and validate the request body
+	if !bindJSON(c, &body) {
 		return
 	}
 
 	// Find the user with the provided email and store the user details in the user variable
 	// Find the user with the provided email
-	user, err := models.GetUserByEmail(body.Email)
+	user, err := stores.Users.GetByEmail(body.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.New(apierror.CodeUserNotFound, "user does not exist"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "user does not exist",
-		})
+		c.Error(apierror.Internal(err, "could not retrieve user"))
 		return
 	}
 
 	// Send an email with the auth token to the user
-	email.SendMailWithSMTP(
-		email.EmailConfig,
-		"Activate your account",
-		"web/reset-password.html",
+	queueEmail(
+		email.ResetPassword,
+		email.Locale(user.Locale, c.GetHeader("Accept-Language")),
 		struct {
 			Name      string
 			AuthToken int
 		}{Name: user.Username, AuthToken: user.AuthToken},
-		[]string{body.Email},
+		body.Email,
 	)
 
+	audit.Record(c, uuid.Nil, audit.PasswordResetRequest, audit.TargetUser, user.ID.String())
+
 	c.JSON(http.StatusOK, types.APISuccessMessage{
 		SuccessMessage: "a code has been sent to your email",
 	})
@@ -373,107 +324,192 @@ func ForgotPassword(c *gin.Context) {
 //	@Tags			Authentication
 //	@Accept			json
 //	@Produce		json
-//	@Param			Credentials	body		types.ActivateAccountRequest	true	"credentials"
+//	@Param			Credentials	body		types.ResetPasswordRequest	true	"credentials"
 //	@Success		200			{object}	types.APISuccessMessage
 //	@Failure		400			{object}	types.APIErrorMessage
 //	@Failure		500			{object}	types.APIErrorMessage
 //	@Router			/auth/reset-password [post]
 func ResetPassword(c *gin.Context) {
-	// Create an instance of models.User to hold the existing user data
-	var user models.User
+	var body types.ResetPasswordRequest
+
+	// Parse and validate the request body
+	if !bindJSON(c, &body) {
+		return
+	}
 
-	// Create a struct to hold the request body
-	var body struct {
-		Email       string `json:"email"`
-		AuthToken   int    `json:"auth_token"`
-		NewPassword string `json:"new_password"`
+	// Find the user with the provided email
+	user, err := stores.Users.GetByEmail(body.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.Internal(err, "could not retrieve user"))
+		return
 	}
 
-	// Parse the request body and store it in the body struct
-	if c.Bind(&body) != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "failed to read request body",
-		})
+	// Return early if there have been too many failed attempts
+	accountKey := lockoutKey(user, body.Email)
+	if !checkLockout(c, accountKey, user) {
 		return
 	}
 
-	// Validations to check for empty fields
-	if !validators.NotBlank(body.Email) || !validators.NotZero(body.AuthToken) || !validators.NotBlank(body.NewPassword) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "some fields are empty",
-		})
+	// Check if the token is valid
+	if user.ID == uuid.Nil || body.AuthToken != user.AuthToken {
+		c.Error(apierror.New(apierror.CodeInvalidToken, "token is invalid"))
 		return
 	}
 
-	// Validations to check for a correct email
-	if !validators.Matches(body.Email, validators.EmailRX) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "invalid email",
-		})
+	clearFailedAttempts(c, accountKey)
+
+	if !checkPasswordPolicy(c, body.NewPassword, user.Username, user.Email) {
 		return
 	}
 
-	// Check if the token is valid
-	if body.AuthToken != user.AuthToken {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "token is invalid",
-		})
+	if err := user.SetPassword(body.NewPassword); err != nil {
+		c.Error(apierror.Internal(err, "failed to hash password"))
 		return
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), 10)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "failed to hash password",
-		})
+	// A reset code can only be used once
+	user.AuthToken = auth.GenerateAuthToken()
+	if err := stores.Users.Save(&user); err != nil {
+		c.Error(apierror.Internal(err, "unable to reset password"))
 		return
 	}
 
-	user.Password = string(hash)
-	database.DB.Save(&user)
+	audit.Record(c, uuid.Nil, audit.PasswordReset, audit.TargetUser, user.ID.String())
 
 	c.JSON(http.StatusOK, types.APISuccessMessage{
 		SuccessMessage: "password has been reset",
 	})
 }
 
+// Refresh Token godoc
+//
+//	@Summary		Refresh Token
+//	@Description	Exchange a refresh token for a new pair of tokens
+//	@Tags			Authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			Token	body		types.RefreshTokenRequest	true	"refresh token"
+//	@Success		200		{object}	types.APISuccessMessage{data=types.TokenResponse}
+//	@Failure		400		{object}	types.APIErrorMessage
+//	@Failure		401		{object}	types.APIErrorMessage
+//	@Failure		500		{object}	types.APIErrorMessage
+//	@Router			/auth/refresh-token [post]
 func RefreshAccessToken(c *gin.Context) {
-	var body struct {
-		RefreshToken string `json:"refresh_token"`
-	}
+	var body types.RefreshTokenRequest
 
-	if err := c.Bind(&body); err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{ErrorMessage: "failed to read request body"})
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	token, err := jwt.Parse(body.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(config.ENV.JWTKey), nil
-	})
-
-	if err != nil || !token.Valid {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "invalid refresh token"})
+	userID, tokenVersion, err := auth.ParseJWTToken(body.RefreshToken, auth.RefreshToken)
+	if err != nil {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "invalid refresh token"))
 		return
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || float64(time.Now().Unix()) > claims["exp"].(float64) {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "expired refresh token"})
+	// Tokens outlive the accounts they were issued for, and the passwords and emails they were issued with
+	user, err := stores.Users.GetByID(userID)
+	if err != nil || !user.IsActive || tokenVersion != user.TokenVersion {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "invalid refresh token"))
 		return
 	}
 
-	userID := claims["sub"].(string)
-	newAccessToken, newRefreshToken, err := auth.CreateJWTTokens(userID)
+	newAccessToken, newRefreshToken, err := auth.CreateJWTTokens(user.ID, user.TokenVersion)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.APIErrorMessage{ErrorMessage: "Failed to generate tokens"})
+		c.Error(apierror.Internal(err, "Failed to generate tokens"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"access_token":  newAccessToken,
-		"refresh_token": newRefreshToken,
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: types.TokenResponse{
+			AccessToken:  newAccessToken,
+			RefreshToken: newRefreshToken,
+		},
 	})
 }
+
+// lockoutKey identifies an account for lockout.Accounts. Unknown accounts are keyed by what the client sent,
+// so guessing against them is throttled the same way.
+func lockoutKey(user models.User, identifier string) string {
+	if user.ID != uuid.Nil {
+		return "user:" + user.ID.String()
+	}
+	return "unknown:" + strings.ToLower(identifier)
+}
+
+// checkLockout counts an attempt against the account and the client's IP before its code or password is compared,
+// and writes a 429 response and returns false if either must wait before trying again. The attempt stays counted as
+// a failure unless it is cleared with clearFailedAttempts, so parallel guesses cannot all be compared before any of
+// them is counted. The user is emailed if this locks their account.
+func checkLockout(c *gin.Context, accountKey string, user models.User) bool {
+	now := time.Now()
+	ipKey := "ip:" + c.ClientIP()
+
+	wait, _, err := lockout.IPs.Reserve(ipKey, now)
+	if err != nil {
+		log.Println("failed to check ip lockout:", err)
+	}
+
+	if wait <= 0 {
+		accountWait, locked, err := lockout.Accounts.Reserve(accountKey, now)
+		if err != nil {
+			log.Println("failed to check account lockout:", err)
+		}
+
+		if locked && user.ID != uuid.Nil {
+			audit.Record(c, uuid.Nil, audit.AccountLocked, audit.TargetUser, user.ID.String())
+
+			queueEmail(
+				email.AccountLocked,
+				email.Locale(user.Locale),
+				struct {
+					Name        string
+					LockedUntil string
+				}{Name: user.Username, LockedUntil: now.Add(lockout.Accounts.Policy.LockoutDuration).UTC().Format(time.RFC1123)},
+				user.Email,
+			)
+		}
+
+		// The attempt is not made, so it does not count against the IP either
+		if accountWait > 0 {
+			wait = accountWait
+			if err := lockout.IPs.Release(ipKey); err != nil {
+				log.Println("failed to release ip attempt:", err)
+			}
+		}
+	}
+
+	if wait <= 0 {
+		return true
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.Error(apierror.New(apierror.CodeAccountLocked, "too many failed attempts, please try again later"))
+	return false
+}
+
+// clearFailedAttempts forgets the failures against an account after it has been accessed successfully, and gives
+// back the attempt that checkLockout counted against the client's IP.
+func clearFailedAttempts(c *gin.Context, accountKey string) {
+	if err := lockout.Accounts.Reset(accountKey); err != nil {
+		log.Println("failed to clear failed attempts:", err)
+	}
+	if err := lockout.IPs.Release("ip:" + c.ClientIP()); err != nil {
+		log.Println("failed to release ip attempt:", err)
+	}
+}
+
+// checkPasswordPolicy writes a 400 response listing every broken rule and returns false if the password is too weak.
+func checkPasswordPolicy(c *gin.Context, pw string, identifiers ...string) bool {
+	problems := password.Default.Check(pw, identifiers...)
+	if len(problems) == 0 {
+		return true
+	}
+
+	err := apierror.New(apierror.CodeWeakPassword, strings.Join(problems, "; "))
+	for _, problem := range problems {
+		err.Fields = append(err.Fields, apierror.FieldError{Field: "password", Message: problem})
+	}
+	c.Error(err)
+	return false
+}
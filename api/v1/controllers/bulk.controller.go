@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/audit"
+	"github.com/topboyasante/go-snip/pkg/events"
+)
+
+// maxSnippetTags is the number of tags a snippet can have, also enforced by the binding tags of the request types.
+const maxSnippetTags = 10
+
+// bulkFailure stops an atomic batch at the operation that failed.
+type bulkFailure struct {
+	index int
+	err   *apierror.Error
+}
+
+func (f *bulkFailure) Error() string {
+	return fmt.Sprintf("operation %d: %v", f.index, f.err)
+}
+
+// Bulk Snippets godoc
+//
+//	@Summary		Bulk snippet operations
+//	@Description	Create, update, delete and tag up to 100 snippets in one request. Atomic batches undo every operation if one fails, and fail with the error of that operation. Other batches report the result of every operation.
+//	@Tags			Snippets
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			Batch	body		types.BulkSnippetRequest	true	"operations"
+//	@Success		200		{object}	types.APISuccessMessage{data=types.BulkSnippetResponse}
+//	@Failure		400		{object}	types.APIErrorMessage
+//	@Failure		403		{object}	types.APIErrorMessage
+//	@Failure		404		{object}	types.APIErrorMessage
+//	@Failure		412		{object}	types.APIErrorMessage
+//	@Failure		500		{object}	types.APIErrorMessage
+//	@Router			/snippets/bulk [post]
+func BulkSnippets(c *gin.Context) {
+	var body types.BulkSnippetRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	user, ok := getActiveUser(c)
+	if !ok {
+		return
+	}
+
+	results := make([]types.BulkSnippetResult, len(body.Operations))
+	snippets := make([]models.Snippet, len(body.Operations))
+	if body.Atomic {
+		err := stores.Transaction(func(tx *store.Store) error {
+			for i, op := range body.Operations {
+				var apiErr *apierror.Error
+				if results[i], snippets[i], apiErr = runBulkOperation(tx, user, i, op); apiErr != nil {
+					return &bulkFailure{index: i, err: apiErr}
+				}
+			}
+			return nil
+		})
+
+		var failure *bulkFailure
+		if errors.As(err, &failure) {
+			c.Error(bulkOperationError(failure.index, failure.err))
+			return
+		}
+		if err != nil {
+			c.Error(apierror.Internal(err, "unable to apply operations"))
+			return
+		}
+	} else {
+		for i, op := range body.Operations {
+			var apiErr *apierror.Error
+			if results[i], snippets[i], apiErr = runBulkOperation(stores, user, i, op); apiErr != nil && apiErr.Err != nil {
+				// Only the error of the response is logged by middleware.ErrorHandler
+				log.Printf("request %s: bulk operation %d: %v", c.GetString("request_id"), i, apiErr)
+			}
+		}
+	}
+
+	for i, result := range results {
+		recordBulkResult(c, user, result, snippets[i])
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: types.BulkSnippetResponse{Results: results},
+	})
+}
+
+// runBulkOperation applies one operation of a batch through s, with the same checks as the single-snippet routes.
+// It also returns the snippet that was written, or deleted.
+func runBulkOperation(s *store.Store, user models.User, index int, op types.BulkSnippetOperation) (types.BulkSnippetResult, models.Snippet, *apierror.Error) {
+	result := types.BulkSnippetResult{Index: index, Op: op.Op, ID: op.ID}
+
+	snippet, apiErr := applyBulkOperation(s, user, op)
+	if apiErr != nil {
+		result.Status = apiErr.Status()
+		result.Error = &types.BulkSnippetError{
+			ErrorMessage: apiErr.Message,
+			Code:         apiErr.Code,
+			Details:      apiErr.Fields,
+		}
+		return result, models.Snippet{}, apiErr
+	}
+
+	result.ID = snippet.ID
+	result.Status = http.StatusOK
+	if op.Op != types.BulkDelete {
+		res := toSnippetResponse(snippet)
+		result.Snippet = &res
+	}
+	return result, snippet, nil
+}
+
+func applyBulkOperation(s *store.Store, user models.User, op types.BulkSnippetOperation) (models.Snippet, *apierror.Error) {
+	if op.Op == types.BulkCreate {
+		snippet, apiErr := newSnippetFromRequest(user, *op.Snippet)
+		if apiErr != nil {
+			return models.Snippet{}, withFieldPrefix(apiErr, "snippet.")
+		}
+		if apiErr := checkSnippetWrite(s.Snippets, snippet, nil); apiErr != nil {
+			return models.Snippet{}, apiErr
+		}
+		if err := s.Snippets.Create(&snippet); err != nil {
+			return models.Snippet{}, apierror.Internal(err, "unable to create snippet")
+		}
+		return snippet, nil
+	}
+
+	action := "update"
+	if op.Op == types.BulkDelete {
+		action = "delete"
+	}
+	snippet, apiErr := getOwnedSnippet(s.Snippets, op.ID, user.ID, action)
+	if apiErr != nil {
+		return models.Snippet{}, apiErr
+	}
+
+	conditional := op.Version != 0
+	if conditional && op.Version != snippet.Version {
+		return models.Snippet{}, apierror.New(apierror.CodePreconditionFailed, "snippet was modified since it was read")
+	}
+
+	previous := snippet
+	switch op.Op {
+	case types.BulkDelete:
+		err := s.Snippets.Delete(snippet.ID, op.Version)
+		if errors.Is(err, store.ErrConflict) {
+			return models.Snippet{}, snippetConflict(conditional, err)
+		}
+		if err != nil {
+			return models.Snippet{}, apierror.Internal(err, "unable to delete snippet")
+		}
+		return snippet, nil
+	case types.BulkUpdate:
+		if apiErr := applySnippetUpdate(&snippet, *op.Update); apiErr != nil {
+			return models.Snippet{}, withFieldPrefix(apiErr, "update.")
+		}
+	case types.BulkTag:
+		if apiErr := applyTagChanges(&snippet, op.AddTags, op.RemoveTags); apiErr != nil {
+			return models.Snippet{}, apiErr
+		}
+	}
+
+	if apiErr := checkSnippetWrite(s.Snippets, snippet, &previous); apiErr != nil {
+		return models.Snippet{}, apiErr
+	}
+
+	err := s.Snippets.Save(&snippet)
+	if errors.Is(err, store.ErrConflict) {
+		return models.Snippet{}, snippetConflict(conditional, err)
+	}
+	if err != nil {
+		return models.Snippet{}, apierror.Internal(err, "unable to update snippet")
+	}
+	return snippet, nil
+}
+
+// applyTagChanges removes and then adds tags, keeping them sorted.
+func applyTagChanges(snippet *models.Snippet, add, remove []string) *apierror.Error {
+	if len(add) == 0 && len(remove) == 0 {
+		return apierror.Invalid(apierror.FieldError{Field: "add_tags", Message: "add_tags or remove_tags is required"})
+	}
+
+	added, apiErr := normalizeTags(add)
+	if apiErr != nil {
+		return withFieldPrefix(apiErr, "add_")
+	}
+	removed, apiErr := normalizeTags(remove)
+	if apiErr != nil {
+		return withFieldPrefix(apiErr, "remove_")
+	}
+
+	tags := make([]string, 0, len(snippet.Tags)+len(added))
+	for _, tag := range snippet.Tags {
+		if !slices.Contains(removed, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range added {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) > maxSnippetTags {
+		return apierror.Invalid(apierror.FieldError{
+			Field:   "add_tags",
+			Message: fmt.Sprintf("a snippet can have at most %d tags", maxSnippetTags),
+		})
+	}
+	slices.Sort(tags)
+	snippet.Tags = tags
+	return nil
+}
+
+// bulkOperationError reports the operation that failed an atomic batch, with its fields nested under it.
+func bulkOperationError(index int, err *apierror.Error) *apierror.Error {
+	wrapped := withFieldPrefix(err, fmt.Sprintf("operations[%d].", index))
+	wrapped.Message = fmt.Sprintf("operation %d failed, no changes were made: %s", index, err.Message)
+	return wrapped
+}
+
+// withFieldPrefix returns a copy of err with prefix added to the name of every invalid field.
+func withFieldPrefix(err *apierror.Error, prefix string) *apierror.Error {
+	prefixed := *err
+	prefixed.Fields = make([]apierror.FieldError, len(err.Fields))
+	for i, field := range err.Fields {
+		field.Field = prefix + field.Field
+		prefixed.Fields[i] = field
+	}
+	return &prefixed
+}
+
+// recordBulkResult audits a successful operation and notifies webhooks of it, the way its single-snippet route
+// would.
+func recordBulkResult(c *gin.Context, user models.User, result types.BulkSnippetResult, snippet models.Snippet) {
+	if result.Error != nil {
+		return
+	}
+
+	action, event := audit.SnippetUpdated, events.SnippetUpdated
+	switch result.Op {
+	case types.BulkCreate:
+		action, event = audit.SnippetCreated, events.SnippetCreated
+	case types.BulkDelete:
+		action, event = audit.SnippetDeleted, events.SnippetDeleted
+	}
+	audit.Record(c, user.ID, action, audit.TargetSnippet, result.ID.String())
+	publishSnippetEvent(event, snippet)
+}
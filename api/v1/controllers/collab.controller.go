@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/audit"
+	"github.com/topboyasante/go-snip/pkg/collab"
+	"github.com/topboyasante/go-snip/pkg/config"
+	"github.com/topboyasante/go-snip/pkg/events"
+	"golang.org/x/net/websocket"
+)
+
+var collabSessions = collab.NewHub(snippetCode{}, config.ENV.CollabCheckpointInterval, config.ENV.SnippetMaxBytes)
+
+// Collaborate godoc
+//
+//	@Summary		Edit a snippet collaboratively
+//	@Description	Join the collaborative editing session of a snippet over a WebSocket, exchanging JSON collab.Message values. The first message is "joined", with the code, the current revision and the participants. Clients send "op" messages with an ot.js operation on the code at the revision they last saw, and receive an "ack" once it is applied, or the "op" messages of others as they are; operations are lengths of text to retain (positive), delete (negative) or strings to insert, counted in Unicode code points. "cursor" messages share selections, "presence" lists the participants as they come and go, and "checkpoint" reports that the code was saved to the snippet, which happens periodically and when the last participant leaves. Only the owner of the snippet and admins can edit; other signed-in users follow along read-only. A client that is dropped after an "error" must join again.
+//	@Tags			Snippets
+//	@Security		ApiKeyAuth
+//	@Param			id	path		string	true	"Snippet ID"
+//	@Success		101	{object}	collab.Message
+//	@Failure		400	{object}	types.APIErrorMessage
+//	@Failure		401	{object}	types.APIErrorMessage
+//	@Failure		403	{object}	types.APIErrorMessage
+//	@Failure		404	{object}	types.APIErrorMessage
+//	@Router			/snippets/{id}/collaborate [get]
+func CollaborateOnSnippet(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.New(apierror.CodeInvalidID, "invalid snippet ID"))
+		return
+	}
+
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+	if !user.IsActive {
+		c.Error(apierror.New(apierror.CodeAccountNotActivated, "account is not activated"))
+		return
+	}
+
+	snippet, err := stores.Snippets.Get(id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.New(apierror.CodeSnippetNotFound, "no snippet exists with the provided ID"))
+		return
+	}
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve snippet"))
+		return
+	}
+
+	participant := collab.Participant{
+		ID:       uuid.NewString(),
+		UserID:   user.ID,
+		Username: user.Username,
+		CanEdit:  snippet.UserID == user.ID || user.Role == models.RoleAdmin,
+	}
+
+	server := websocket.Server{
+		// Clients authenticate with a header rather than a cookie, so any origin may connect, as with CORS
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			conn, err := collabSessions.Join(id, participant)
+			if err != nil {
+				reason := "could not join the session"
+				if errors.Is(err, store.ErrNotFound) {
+					reason = "the snippet no longer exists"
+				}
+				websocket.JSON.Send(ws, collab.Message{Type: collab.Closed, Error: reason})
+				return
+			}
+			defer conn.Leave()
+
+			// The session closes the messages of clients it drops, which closes their connection
+			go func() {
+				for msg := range conn.Messages() {
+					if websocket.JSON.Send(ws, msg) != nil {
+						break
+					}
+				}
+				ws.Close()
+			}()
+
+			for {
+				var msg collab.Message
+				if err := websocket.JSON.Receive(ws, &msg); err != nil {
+					return
+				}
+				conn.Handle(msg)
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// snippetCode lets collaborative sessions load and checkpoint the code of snippets through the store.
+// Checkpoints are audited and published like any other update.
+type snippetCode struct{}
+
+func (snippetCode) Load(id uuid.UUID) (string, int64, error) {
+	snippet, err := stores.Snippets.Get(id)
+	return snippet.Code, snippet.Version, err
+}
+
+func (snippetCode) Save(id uuid.UUID, code string, version int64, editor uuid.UUID) (int64, error) {
+	snippet, err := stores.Snippets.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	if snippet.Version != version {
+		return 0, store.ErrConflict
+	}
+
+	previous := snippet
+	snippet.Code = code
+	if apiErr := checkSnippetWrite(stores.Snippets, snippet, &previous); apiErr != nil {
+		if apiErr.Code == apierror.CodeInternal {
+			return 0, apiErr
+		}
+		return 0, &collab.RejectedError{Reason: apiErr.Message}
+	}
+	if err := stores.Snippets.Save(&snippet); err != nil {
+		return 0, err
+	}
+
+	audit.RecordBackground(editor, audit.SnippetUpdated, audit.TargetSnippet, snippet.ID.String())
+	publishSnippetEvent(events.SnippetUpdated, snippet)
+	return snippet.Version, nil
+}
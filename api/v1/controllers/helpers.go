@@ -0,0 +1,483 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/email"
+	"github.com/topboyasante/go-snip/pkg/events"
+	"github.com/topboyasante/go-snip/pkg/placeholder"
+	"github.com/topboyasante/go-snip/pkg/validators"
+	"github.com/topboyasante/go-snip/pkg/webhook"
+	"golang.org/x/text/language"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// getAuthenticatedUserID returns the ID that middleware.RequireAuth stored on the context.
+func getAuthenticatedUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+
+	uID, ok := userID.(uuid.UUID)
+	return uID, ok
+}
+
+// getAuthenticatedUser loads the signed-in user, writing an error response and returning false if that fails.
+func getAuthenticatedUser(c *gin.Context) (models.User, bool) {
+	uID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "unauthorized request"))
+		return models.User{}, false
+	}
+
+	user, err := stores.Users.GetByID(uID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "unauthorized request"))
+		return models.User{}, false
+	}
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve user"))
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+// getActiveUser is getAuthenticatedUser for writes, which also require the account to be activated.
+func getActiveUser(c *gin.Context) (models.User, bool) {
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return models.User{}, false
+	}
+	if !user.IsActive {
+		c.Error(apierror.New(apierror.CodeAccountNotActivated, "account is not activated"))
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// bindJSON decodes and validates the request body against its binding tags, reporting every invalid field at once.
+func bindJSON(c *gin.Context, body any) bool {
+	err := c.ShouldBindJSON(body)
+	if err == nil {
+		return true
+	}
+
+	if fields, ok := validators.FieldErrors(err); ok {
+		c.Error(apierror.Invalid(fields...))
+	} else {
+		c.Error(bodyReadError(err))
+	}
+	return false
+}
+
+// bodyReadError reports a request body that could not be read, telling bodies over middleware.LimitRequestBody
+// apart from malformed ones.
+func bodyReadError(err error) *apierror.Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apierror.New(apierror.CodeRequestTooLarge, fmt.Sprintf("request bodies cannot be larger than %d bytes", tooLarge.Limit))
+	}
+	return apierror.New(apierror.CodeInvalidBody, "failed to read request body")
+}
+
+// bindQuery is bindJSON for query parameters.
+func bindQuery(c *gin.Context, query any) bool {
+	err := c.ShouldBindQuery(query)
+	if err == nil {
+		return true
+	}
+
+	if fields, ok := validators.FieldErrors(err); ok {
+		c.Error(apierror.Invalid(fields...))
+	} else {
+		c.Error(apierror.New(apierror.CodeBadRequest, "invalid query parameters: "+err.Error()))
+	}
+	return false
+}
+
+// getPagination reads the page and page_size query parameters, falling back to sane defaults.
+func getPagination(c *gin.Context) (page, pageSize int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err = strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	return page, pageSize
+}
+
+func toUserProfileResponse(user models.User) types.UserProfileResponse {
+	return types.UserProfileResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
+		Website:     user.Website,
+		CreatedAt:   user.CreatedAt,
+	}
+}
+
+func toMeResponse(user models.User) types.MeResponse {
+	return types.MeResponse{
+		UserProfileResponse: toUserProfileResponse(user),
+		Email:               user.Email,
+		PendingEmail:        user.PendingEmail,
+		IsActive:            user.IsActive,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		Locale:              user.Locale,
+	}
+}
+
+func toSnippetResponse(snippet models.Snippet) types.NewSnippetResponse {
+	return types.NewSnippetResponse{
+		ID:          snippet.ID,
+		Title:       snippet.Title,
+		Description: snippet.Description,
+		Code:        snippet.Code,
+		Trigger:     snippet.Trigger,
+		UserID:      snippet.UserID,
+		CreatedAt:   snippet.CreatedAt,
+		UpdatedAt:   snippet.UpdatedAt,
+		CreatedBy:   snippet.User.Username,
+		ExpiresAt:   snippet.ExpiresAt,
+		Tags:        snippet.Tags,
+		Variables:   toVariableResponses(snippet.Variables),
+		Version:     snippet.Version,
+	}
+}
+
+func toVariableResponses(variables []models.SnippetVariable) []types.SnippetVariable {
+	if len(variables) == 0 {
+		return nil
+	}
+
+	responses := make([]types.SnippetVariable, 0, len(variables))
+	for _, v := range variables {
+		response := types.SnippetVariable{
+			Name:        v.Name,
+			Type:        v.Type,
+			Description: v.Description,
+			Pattern:     v.Pattern,
+			Min:         v.Min,
+			Max:         v.Max,
+			Options:     v.Options,
+		}
+		if v.Default != nil {
+			value := placeholder.Value(*v.Default)
+			response.Default = &value
+		}
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+// toSnippetVariables checks the variables of a request, which default to strings. Their number and the length of
+// their fields are checked when the request is bound.
+func toSnippetVariables(requests []types.SnippetVariable) ([]models.SnippetVariable, *apierror.Error) {
+	variables := make([]models.SnippetVariable, 0, len(requests))
+	for _, r := range requests {
+		v := models.SnippetVariable{
+			Name:        r.Name,
+			Type:        r.Type,
+			Description: r.Description,
+			Pattern:     r.Pattern,
+			Min:         r.Min,
+			Max:         r.Max,
+			Options:     r.Options,
+		}
+		if v.Type == "" {
+			v.Type = models.VariableString
+		}
+		if r.Default != nil {
+			value := string(*r.Default)
+			v.Default = &value
+		}
+		variables = append(variables, v)
+	}
+
+	if errs := placeholder.Check(variables); len(errs) > 0 {
+		return nil, apierror.Invalid(errs...)
+	}
+	return variables, nil
+}
+
+// normalizeTag lowercases and trims a tag, so that "Go" and " go" are the same tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags normalizes, de-duplicates and sorts the tags of a snippet, which is the order the stores return
+// them in. Their number and length are checked when the request is bound.
+func normalizeTags(tags []string) ([]string, *apierror.Error) {
+	normalized := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+		if strings.ContainsAny(tag, " \t,") {
+			return nil, apierror.Invalid(apierror.FieldError{
+				Field:   fmt.Sprintf("tags[%d]", i),
+				Message: "cannot contain spaces or commas",
+			})
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return normalized, nil
+}
+
+// getOwnedSnippet loads a snippet that userID is about to change. action completes "you are not authorized to ...".
+func getOwnedSnippet(snippets store.SnippetStore, id, userID uuid.UUID, action string) (models.Snippet, *apierror.Error) {
+	snippet, err := snippets.Get(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.Snippet{}, apierror.New(apierror.CodeSnippetNotFound, "no snippet exists with the provided ID")
+	}
+	if err != nil {
+		return models.Snippet{}, apierror.Internal(err, "could not retrieve snippet")
+	}
+
+	if snippet.UserID != userID {
+		return models.Snippet{}, apierror.New(apierror.CodeNotOwner, "you are not authorized to "+action+" this snippet")
+	}
+	return snippet, nil
+}
+
+// snippetETag is the strong entity tag of a snippet, which changes every time the snippet is saved.
+func snippetETag(snippet models.Snippet) string {
+	return `"` + strconv.FormatInt(snippet.Version, 10) + `"`
+}
+
+// etagMatches reports whether a list of entity tags from an If-Match or If-None-Match header includes etag.
+// If-Match compares tags strongly, so weak tags only match when weak is true.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch rejects a write to a snippet that was modified since the client read it, when the client sent an
+// If-Match header. The response carries the current ETag so that the client can fetch the snippet again.
+func checkIfMatch(c *gin.Context, snippet models.Snippet) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" || etagMatches(header, snippetETag(snippet), false) {
+		return true
+	}
+
+	c.Header("ETag", snippetETag(snippet))
+	c.Error(apierror.New(apierror.CodePreconditionFailed, "snippet was modified since it was read"))
+	return false
+}
+
+// snippetConflict is the error for a write that lost a race with another one, after checkIfMatch passed.
+// Conditional requests get the 412 they asked for; the rest are told to retry.
+func snippetConflict(conditional bool, err error) *apierror.Error {
+	if conditional {
+		return apierror.Wrap(err, apierror.CodePreconditionFailed, "snippet was modified since it was read")
+	}
+	return apierror.Wrap(err, apierror.CodeConflict, "snippet was modified concurrently, please retry")
+}
+
+// bindMergePatch decodes a JSON Merge Patch (RFC 7396) into patch, a struct of pointers, and validates it against
+// its binding tags. It returns the members of the patch, so that the caller can tell null members, which clear a
+// field, from absent ones. Members that are not fields of patch, and null members for the fields named in required,
+// are rejected along with the fields that fail validation.
+func bindMergePatch(c *gin.Context, patch any, required ...string) (map[string]json.RawMessage, bool) {
+	switch c.ContentType() {
+	case "application/merge-patch+json", "application/json", "":
+	default:
+		c.Error(apierror.New(apierror.CodeUnsupportedMedia, "patches must be sent as application/merge-patch+json"))
+		return nil, false
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		c.Error(bodyReadError(err))
+		return nil, false
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil || members == nil {
+		c.Error(apierror.New(apierror.CodeInvalidBody, "request body must be a JSON object"))
+		return nil, false
+	}
+	if err := json.Unmarshal(data, patch); err != nil {
+		c.Error(apierror.New(apierror.CodeInvalidBody, "failed to read request body"))
+		return nil, false
+	}
+
+	var fields []apierror.FieldError
+	known := jsonFieldNames(patch)
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		switch {
+		case !slices.Contains(known, name):
+			fields = append(fields, apierror.FieldError{Field: name, Message: "is not a field that can be changed"})
+		case isJSONNull(members[name]) && slices.Contains(required, name):
+			fields = append(fields, apierror.FieldError{Field: name, Message: "cannot be removed"})
+		}
+	}
+
+	if err := binding.Validator.ValidateStruct(patch); err != nil {
+		invalid, ok := validators.FieldErrors(err)
+		if !ok {
+			c.Error(apierror.New(apierror.CodeInvalidBody, "failed to read request body"))
+			return nil, false
+		}
+		fields = append(fields, invalid...)
+	}
+
+	if len(fields) > 0 {
+		c.Error(apierror.Invalid(fields...))
+		return nil, false
+	}
+	return members, true
+}
+
+// jsonFieldNames lists the json names of the fields of the struct that v points to.
+func jsonFieldNames(v any) []string {
+	t := reflect.TypeOf(v).Elem()
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
+
+// queueEmail queues an email in the outbox. Failures are logged rather than returned, so that email never breaks
+// the action that sends it.
+func queueEmail(name, locale string, values any, to ...string) {
+	if err := email.Enqueue(name, locale, values, to...); err != nil {
+		log.Printf("failed to send %s email: %v", name, err)
+	}
+}
+
+// publishSnippetEvent publishes a change to a snippet on the stream, and queues it for the webhooks of the owner
+// of the snippet. Failures are logged rather than returned, so that they never break the action that triggers them.
+func publishSnippetEvent(eventType string, snippet models.Snippet) {
+	data := types.SnippetEvent{Snippet: toSnippetResponse(snippet)}
+
+	// Expired snippets are hidden from the snippet routes, so only their owner may hear of them
+	events.Snippets.Publish(events.Event{
+		Type:      eventType,
+		OwnerID:   snippet.UserID,
+		SnippetID: snippet.ID,
+		Tags:      snippet.Tags,
+		Public:    !snippet.IsExpired(time.Now()),
+		Data:      data,
+	})
+
+	if err := webhook.Dispatch(snippet.UserID, eventType, data); err != nil {
+		log.Printf("failed to notify webhooks of %s: %v", eventType, err)
+	}
+}
+
+// uniqueEvents de-duplicates the events of a webhook, listing them in the order of webhook.Events. Unknown
+// events are rejected when the request is bound.
+func uniqueEvents(requested []string) []string {
+	unique := make([]string, 0, len(requested))
+	for _, event := range webhook.Events {
+		if slices.Contains(requested, event) {
+			unique = append(unique, event)
+		}
+	}
+	return unique
+}
+
+func toWebhookResponse(w models.Webhook) types.WebhookResponse {
+	return types.WebhookResponse{
+		ID:                  w.ID,
+		URL:                 w.URL,
+		Events:              w.Events,
+		Active:              w.Active,
+		CreatedAt:           w.CreatedAt,
+		UpdatedAt:           w.UpdatedAt,
+		ConsecutiveFailures: w.ConsecutiveFailures,
+		DisabledAt:          w.DisabledAt,
+	}
+}
+
+func toWebhookDeliveryResponse(delivery models.WebhookDelivery) types.WebhookDeliveryResponse {
+	res := types.WebhookDeliveryResponse{
+		ID:           delivery.ID,
+		Event:        delivery.Event,
+		Payload:      delivery.Payload,
+		RedeliveryOf: delivery.RedeliveryOf,
+		CreatedAt:    delivery.CreatedAt,
+		Attempts:     delivery.Attempts,
+		StatusCode:   delivery.StatusCode,
+		DurationMS:   delivery.DurationMS,
+		LastError:    delivery.LastError,
+		DeliveredAt:  delivery.DeliveredAt,
+		FailedAt:     delivery.FailedAt,
+	}
+	switch {
+	case delivery.DeliveredAt != nil:
+		res.Status = "delivered"
+	case delivery.FailedAt != nil:
+		res.Status = "failed"
+	default:
+		res.Status = "pending"
+		res.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return res
+}
+
+// acceptedLanguage returns the language the client prefers most in its Accept-Language header, or "" if it did
+// not say.
+func acceptedLanguage(c *gin.Context) string {
+	tags, _, err := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+	if err != nil || len(tags) == 0 || tags[0] == language.Und {
+		return ""
+	}
+	return tags[0].String()
+}
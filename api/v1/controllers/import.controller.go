@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/audit"
+	"github.com/topboyasante/go-snip/pkg/events"
+	"github.com/topboyasante/go-snip/pkg/importer"
+	"github.com/topboyasante/go-snip/pkg/validators"
+)
+
+const (
+	maxImportSize  = 10 << 20
+	maxImportItems = 1000
+)
+
+// Import Snippets godoc
+//
+//	@Summary		Import snippets
+//	@Description	Import snippets from the files of another tool, sent as the "files" fields of a multipart form. Formats are gist (GitHub API JSON), vscode (.code-snippets or language .json files), jetbrains (live template XML) and dir (one snippet per file, titled with its path). Snippets whose title is already taken are skipped, overwritten or created anyway, depending on on_conflict. A dry run reports what would happen without saving anything.
+//	@Tags			Snippets
+//	@Accept			mpfd
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			format		query		string	true	"Format of the files"
+//	@Param			dry_run		query		bool	false	"Report what would be imported without saving anything"
+//	@Param			on_conflict	query		string	false	"skip (default), overwrite or create"
+//	@Param			files		formData	file	true	"Files to import"
+//	@Success		200			{object}	types.APISuccessMessage{data=types.ImportSnippetsResponse}
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		403			{object}	types.APIErrorMessage
+//	@Failure		413			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/snippets/import [post]
+func ImportSnippets(c *gin.Context) {
+	var query types.ImportSnippetsQuery
+
+	if !bindQuery(c, &query) {
+		return
+	}
+	if _, ok := importer.Lookup(query.Format); !ok {
+		c.Error(apierror.Invalid(apierror.FieldError{
+			Field:   "format",
+			Message: "must be one of " + strings.Join(importer.Formats(), ", "),
+		}))
+		return
+	}
+	if query.OnConflict == "" {
+		query.OnConflict = types.OnConflictSkip
+	}
+
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.IsActive {
+		c.Error(apierror.New(apierror.CodeAccountNotActivated, "account is not activated"))
+		return
+	}
+
+	files, apiErr := readImportFiles(c)
+	if apiErr != nil {
+		c.Error(apiErr)
+		return
+	}
+
+	items, err := importer.Parse(query.Format, files)
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not read files"))
+		return
+	}
+	if len(items) > maxImportItems {
+		c.Error(apierror.Invalid(apierror.FieldError{
+			Field:   "files",
+			Message: fmt.Sprintf("can contain at most %d snippets", maxImportItems),
+		}))
+		return
+	}
+
+	existing, _, err := stores.Snippets.List(store.SnippetQuery{UserID: user.ID})
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve snippets"))
+		return
+	}
+
+	res := types.ImportSnippetsResponse{DryRun: query.DryRun, Items: make([]types.ImportItemResult, len(items))}
+	writes := planImport(user, items, existing, query.OnConflict, res.Items)
+
+	// The whole import is refused rather than stopping halfway through the quota
+	newSnippets, newBytes := importGrowth(existing, res.Items, writes)
+	if apiErr := checkQuota(stores.Snippets, user.ID, newSnippets, newBytes); apiErr != nil {
+		c.Error(apiErr)
+		return
+	}
+
+	if !query.DryRun {
+		err := stores.Transaction(func(tx *store.Store) error {
+			for i := range res.Items {
+				snippet, ok := writes[i]
+				if !ok {
+					continue
+				}
+
+				var err error
+				if res.Items[i].Action == types.ImportCreate {
+					err = tx.Snippets.Create(&snippet)
+				} else {
+					err = tx.Snippets.Save(&snippet)
+				}
+				if err != nil {
+					return err
+				}
+				writes[i] = snippet
+				res.Items[i].SnippetID = &snippet.ID
+			}
+			return nil
+		})
+		if errors.Is(err, store.ErrConflict) {
+			c.Error(snippetConflict(false, err))
+			return
+		}
+		if err != nil {
+			c.Error(apierror.Internal(err, "unable to import snippets"))
+			return
+		}
+	}
+
+	for i, item := range res.Items {
+		switch item.Action {
+		case types.ImportCreate:
+			res.Created++
+		case types.ImportUpdate:
+			res.Updated++
+		case types.ImportSkip:
+			res.Skipped++
+		case types.ImportError:
+			res.Failed++
+		}
+
+		if !query.DryRun && item.SnippetID != nil {
+			action, event := audit.SnippetCreated, events.SnippetCreated
+			if item.Action == types.ImportUpdate {
+				action, event = audit.SnippetUpdated, events.SnippetUpdated
+			}
+			audit.Record(c, user.ID, action, audit.TargetSnippet, item.SnippetID.String())
+			publishSnippetEvent(event, writes[i])
+		}
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: res,
+	})
+}
+
+// planImport decides what to do with every imported item, filling in results, and returns the snippets to create
+// or overwrite by the index of their item. A title may only be used once per import.
+func planImport(user models.User, items []importer.Item, existing []models.Snippet, onConflict string, results []types.ImportItemResult) map[int]models.Snippet {
+	byTitle := make(map[string]models.Snippet, len(existing))
+	for _, snippet := range existing {
+		byTitle[snippet.Title] = snippet
+	}
+
+	writes := make(map[int]models.Snippet)
+	imported := make(map[string]bool)
+	for i, item := range items {
+		result := &results[i]
+		result.Source = item.Source
+		result.Title = item.Snippet.Title
+
+		if item.Err != nil {
+			result.Action, result.Reason = types.ImportError, item.Err.Error()
+			continue
+		}
+
+		snippet, apiErr := importedSnippet(user, item.Snippet)
+		if apiErr != nil {
+			result.Action, result.Reason, result.Details = types.ImportError, apiErr.Message, apiErr.Fields
+			continue
+		}
+
+		if imported[snippet.Title] {
+			result.Action, result.Reason = types.ImportSkip, "an earlier snippet in the import has the same title"
+			continue
+		}
+		imported[snippet.Title] = true
+
+		current, conflict := byTitle[snippet.Title]
+		if !conflict {
+			result.Action = types.ImportCreate
+			writes[i] = snippet
+			continue
+		}
+
+		result.ConflictsWith = &current.ID
+		switch onConflict {
+		case types.OnConflictOverwrite:
+			current.Description, current.Code, current.Tags = snippet.Description, snippet.Code, snippet.Tags
+			current.Trigger = snippet.Trigger
+			result.Action = types.ImportUpdate
+			writes[i] = current
+		case types.OnConflictCreate:
+			result.Action = types.ImportCreate
+			writes[i] = snippet
+		default:
+			result.Action, result.Reason = types.ImportSkip, "a snippet with this title already exists"
+		}
+	}
+	return writes
+}
+
+// importGrowth returns how many snippets, and how many bytes of code, an import adds to what the user stores.
+func importGrowth(existing []models.Snippet, results []types.ImportItemResult, writes map[int]models.Snippet) (snippets, bytes int64) {
+	sizes := make(map[uuid.UUID]int, len(existing))
+	for _, snippet := range existing {
+		sizes[snippet.ID] = len(snippet.Code)
+	}
+
+	for i, snippet := range writes {
+		bytes += int64(len(snippet.Code))
+		if results[i].Action == types.ImportCreate {
+			snippets++
+		} else {
+			bytes -= int64(sizes[snippet.ID])
+		}
+	}
+	return snippets, bytes
+}
+
+// importedSnippet checks an imported snippet like a NewSnippetRequest, and returns it ready to be created.
+func importedSnippet(user models.User, snippet models.Snippet) (models.Snippet, *apierror.Error) {
+	body := types.NewSnippetRequest{
+		Title:       snippet.Title,
+		Description: snippet.Description,
+		Code:        snippet.Code,
+		Tags:        snippet.Tags,
+		Trigger:     snippet.Trigger,
+	}
+	if err := binding.Validator.ValidateStruct(&body); err != nil {
+		fields, ok := validators.FieldErrors(err)
+		if !ok {
+			return models.Snippet{}, apierror.Internal(err, "could not validate snippet")
+		}
+		return models.Snippet{}, apierror.Invalid(fields...)
+	}
+	if apiErr := checkSnippetSize(body.Code, 0); apiErr != nil {
+		return models.Snippet{}, apiErr
+	}
+
+	return newSnippetFromRequest(user, body)
+}
+
+// readImportFiles reads the files of an import form. Files keep the directories in their names, which the
+// multipart package would drop.
+func readImportFiles(c *gin.Context) ([]importer.File, *apierror.Error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, bodyReadError(err)
+		}
+		return nil, apierror.New(apierror.CodeInvalidBody, "request body must be a multipart form with the files to import")
+	}
+
+	headers := form.File["files"]
+	if len(headers) == 0 {
+		return nil, apierror.Invalid(apierror.FieldError{Field: "files", Message: "is required"})
+	}
+
+	files := make([]importer.File, 0, len(headers))
+	for _, header := range headers {
+		name := header.Filename
+		if _, params, err := mime.ParseMediaType(header.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+			name = strings.TrimLeft(path.Clean(strings.ReplaceAll(params["filename"], "\\", "/")), "/")
+		}
+
+		f, err := header.Open()
+		if err != nil {
+			return nil, apierror.Internal(err, "could not read "+name)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, apierror.Internal(err, "could not read "+name)
+		}
+
+		files = append(files, importer.File{Name: name, Data: data})
+	}
+	return files, nil
+}
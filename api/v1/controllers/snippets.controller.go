@@ -1,15 +1,20 @@
 package controllers
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/store"
 	"github.com/topboyasante/go-snip/internal/types"
-	"github.com/topboyasante/go-snip/pkg/validators"
-	"gorm.io/gorm"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/audit"
+	"github.com/topboyasante/go-snip/pkg/config"
+	"github.com/topboyasante/go-snip/pkg/events"
+	"github.com/topboyasante/go-snip/pkg/placeholder"
 )
 
 // Get All Snippets godoc
@@ -19,21 +24,25 @@ import (
 //	@Tags			Snippets
 //	@Accept			json
 //	@Produce		json
+//	@Param			tag	query		string	false	"Only return snippets with this tag"
 //	@Success		200	{object}	types.APISuccessMessage
 //	@Failure		400	{object}	types.APIErrorMessage
 //	@Failure		500	{object}	types.APIErrorMessage
 //	@Router			/snippets [get]
 func GetSnippets(c *gin.Context) {
-	snippets, err := models.GetSnippets()
+	snippets, _, err := stores.Snippets.List(store.SnippetQuery{Tag: normalizeTag(c.Query("tag"))})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "could not retrieve snippets",
-		})
+		c.Error(apierror.Internal(err, "could not retrieve snippets"))
 		return
 	}
 
+	items := make([]types.NewSnippetResponse, 0, len(snippets))
+	for _, snippet := range snippets {
+		items = append(items, toSnippetResponse(snippet))
+	}
+
 	c.JSON(200, types.APISuccessMessage{
-		Data: snippets,
+		Data: items,
 	})
 }
 
@@ -44,24 +53,39 @@ func GetSnippets(c *gin.Context) {
 //	@Tags			Snippets
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		string	true	"Snippet ID"
-//	@Success		200	{object}	types.APISuccessMessage
-//	@Failure		400	{object}	types.APIErrorMessage
-//	@Failure		500	{object}	types.APIErrorMessage
+//	@Param			id				path		string	true	"Snippet ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy of the snippet"
+//	@Success		200				{object}	types.APISuccessMessage
+//	@Success		304				"snippet has not changed"
+//	@Failure		400				{object}	types.APIErrorMessage
+//	@Failure		500				{object}	types.APIErrorMessage
 //	@Router			/snippets/{id} [get]
 func GetSnippet(c *gin.Context) {
-	id := c.Param("id")
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.New(apierror.CodeInvalidID, "invalid snippet ID"))
+		return
+	}
 
-	snippet, err := models.GetSnippet(id)
+	snippet, err := stores.Snippets.Get(id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "could not retrieve snippet",
-		})
+		if errors.Is(err, store.ErrNotFound) {
+			c.Error(apierror.New(apierror.CodeSnippetNotFound, "no snippet exists with the provided ID"))
+			return
+		}
+		c.Error(apierror.Internal(err, "could not retrieve snippet"))
+		return
+	}
+
+	etag := snippetETag(snippet)
+	c.Header("ETag", etag)
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		c.Status(http.StatusNotModified)
 		return
 	}
 
 	c.JSON(200, types.APISuccessMessage{
-		Data: snippet,
+		Data: toSnippetResponse(snippet),
 	})
 }
 
@@ -79,88 +103,38 @@ func GetSnippet(c *gin.Context) {
 //	@Failure		500		{object}	types.APIErrorMessage
 //	@Router			/snippets/create [post]
 func CreateSnippet(c *gin.Context) {
-	var body struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		Code        string `json:"code"`
-	}
-
-	if c.Bind(&body) != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "failed to read request body",
-		})
-		return
-	}
+	var body types.NewSnippetRequest
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "unauthorized request"})
+	if !bindJSON(c, &body) {
 		return
 	}
 
-	// Type Assertion
-	uID, ok := userID.(uuid.UUID)
+	user, ok := getActiveUser(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "unauthorized request"})
 		return
 	}
 
-	if !validators.NotBlank(body.Title) ||
-		!validators.NotBlank(body.Code) {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "some fields are empty",
-		})
-		return
-	}
-
-	user, err := models.GetUserById(uID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-				ErrorMessage: "no user exists with the provided user ID",
-			})
-			return
-		}
+	newSnippet, apiErr := newSnippetFromRequest(user, body)
+	if apiErr != nil {
+		c.Error(apiErr)
 		return
 	}
-
-	if !user.IsActive {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "account is not activated",
-		})
+	if apiErr := checkSnippetWrite(stores.Snippets, newSnippet, nil); apiErr != nil {
+		c.Error(apiErr)
 		return
 	}
 
-	newSnippet := &models.Snippet{
-		Title:       body.Title,
-		Description: body.Description,
-		Code:        body.Code,
-		UserID:      user.ID,
-		User:        user,
-	}
-
-	newSnippet.ID = uuid.New()
-	res, err := newSnippet.Create()
-	if err != nil {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "unable to create snippet",
-		})
+	res := &newSnippet
+	if err := stores.Snippets.Create(res); err != nil {
+		c.Error(apierror.Internal(err, "unable to create snippet"))
 		return
 	}
 
-	snippetRes := &types.NewSnippetResponse{
-		ID:          res.ID,
-		Title:       res.Title,
-		Description: res.Description,
-		Code:        res.Code,
-		UserID:      res.UserID,
-		CreatedAt:   res.CreatedAt,
-		UpdatedAt:   res.UpdatedAt,
-		CreatedBy:   res.User.Username,
-	}
+	audit.Record(c, user.ID, audit.SnippetCreated, audit.TargetSnippet, res.ID.String())
+	publishSnippetEvent(events.SnippetCreated, *res)
 
 	c.JSON(http.StatusOK, types.APISuccessMessage{
-		Data: snippetRes,
+		Data: toSnippetResponse(*res),
 	})
 }
 
@@ -173,69 +147,54 @@ func CreateSnippet(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Security		ApiKeyAuth
-//	@Param			id	path		string	true	"Snippet ID"
-//	@Success		200		{object}	types.APISuccessMessage
-//	@Failure		400		{object}	types.APIErrorMessage
-//	@Failure		500		{object}	types.APIErrorMessage
+//	@Param			id			path		string	true	"Snippet ID"
+//	@Param			If-Match	header		string	false	"Only delete the snippet if it is still at this ETag"
+//	@Success		200			{object}	types.APISuccessMessage
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		412			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
 //	@Router			/snippets/{id} [delete]
 func DeleteSnippet(c *gin.Context) {
-	id := c.Param("id")
-
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "unauthorized request"})
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.New(apierror.CodeInvalidID, "invalid snippet ID"))
 		return
 	}
 
-	// Type Assertion
-	uID, ok := userID.(uuid.UUID)
+	user, ok := getActiveUser(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "unauthorized request"})
 		return
 	}
 
-	user, err := models.GetUserById(uID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-				ErrorMessage: "no user exists with the provided user ID",
-			})
-			return
-		}
+	snippet, apiErr := getOwnedSnippet(stores.Snippets, id, user.ID, "delete")
+	if apiErr != nil {
+		c.Error(apiErr)
 		return
 	}
 
-	if !user.IsActive {
-		c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-			ErrorMessage: "account is not activated",
-		})
+	if !checkIfMatch(c, snippet) {
 		return
 	}
 
-	snippet, err := models.GetSnippetWithUser(user.ID, id)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-				ErrorMessage: "no snippet exists with the provided queries",
-			})
-			return
-		}
-		return
+	// An unconditional delete removes whatever version is stored
+	var version int64
+	if c.GetHeader("If-Match") != "" {
+		version = snippet.Version
 	}
 
-	if snippet.UserID != uID {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "you are not authorized to delete this snippet"})
+	err = stores.Snippets.Delete(snippet.ID, version)
+	if errors.Is(err, store.ErrConflict) {
+		c.Error(snippetConflict(c.GetHeader("If-Match") != "", err))
 		return
 	}
-
-	err = snippet.Delete()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.APIErrorMessage{
-			ErrorMessage: "unable to delete snippet",
-		})
+		c.Error(apierror.Internal(err, "unable to delete snippet"))
 		return
 	}
 
+	audit.Record(c, user.ID, audit.SnippetDeleted, audit.TargetSnippet, snippet.ID.String())
+	publishSnippetEvent(events.SnippetDeleted, snippet)
+
 	c.JSON(http.StatusOK, types.APISuccessMessage{
 		Data: "snippet deleted",
 	})
@@ -249,61 +208,308 @@ func DeleteSnippet(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Security		ApiKeyAuth
-//	@Param			id	path		string	true	"Snippet ID"
-//	@Param			Snippet	body		types.NewSnippetRequest	true	"snippet"
-//	@Success		200		{object}	types.APISuccessMessage
-//	@Failure		400		{object}	types.APIErrorMessage
-//	@Failure		500		{object}	types.APIErrorMessage
+//	@Param			id			path		string						true	"Snippet ID"
+//	@Param			If-Match	header		string						false	"Only update the snippet if it is still at this ETag"
+//	@Param			Snippet		body		types.UpdateSnippetRequest	true	"snippet"
+//	@Success		200			{object}	types.APISuccessMessage
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		409			{object}	types.APIErrorMessage
+//	@Failure		412			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
 //	@Router			/snippets/{id} [put]
 func UpdateSnippet(c *gin.Context) {
-	var body struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		Code        string `json:"code"`
+	var body types.UpdateSnippetRequest
+
+	if !bindJSON(c, &body) {
+		return
 	}
 
-	c.Bind(&body)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.New(apierror.CodeInvalidID, "invalid snippet ID"))
+		return
+	}
+
+	user, ok := getActiveUser(c)
+	if !ok {
+		return
+	}
 
-	id := c.Param("id")
+	snippet, apiErr := getOwnedSnippet(stores.Snippets, id, user.ID, "update")
+	if apiErr != nil {
+		c.Error(apiErr)
+		return
+	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "unauthorized request"})
+	if !checkIfMatch(c, snippet) {
 		return
 	}
 
-	// Type Assertion
-	uID, ok := userID.(uuid.UUID)
+	previous := snippet
+	if apiErr := applySnippetUpdate(&snippet, body); apiErr != nil {
+		c.Error(apiErr)
+		return
+	}
+	if apiErr := checkSnippetWrite(stores.Snippets, snippet, &previous); apiErr != nil {
+		c.Error(apiErr)
+		return
+	}
+	err = stores.Snippets.Save(&snippet)
+	if errors.Is(err, store.ErrConflict) {
+		c.Error(snippetConflict(c.GetHeader("If-Match") != "", err))
+		return
+	}
+	if err != nil {
+		c.Error(apierror.Internal(err, "unable to update snippet"))
+		return
+	}
+
+	audit.Record(c, user.ID, audit.SnippetUpdated, audit.TargetSnippet, snippet.ID.String())
+	publishSnippetEvent(events.SnippetUpdated, snippet)
+
+	c.Header("ETag", snippetETag(snippet))
+	c.JSON(200, types.APISuccessMessage{
+		Data: "snippet updated",
+	})
+}
+
+// Patch Snippet godoc
+//
+//	@Summary		Patch Snippet
+//	@Description	Apply a JSON Merge Patch to a snippet. Absent fields are left unchanged, and null clears description, expires_at and tags.
+//	@Tags			Snippets
+//	@Accept			application/merge-patch+json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id			path		string						true	"Snippet ID"
+//	@Param			If-Match	header		string						false	"Only patch the snippet if it is still at this ETag"
+//	@Param			Snippet		body		types.PatchSnippetRequest	true	"patch"
+//	@Success		200			{object}	types.APISuccessMessage
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		409			{object}	types.APIErrorMessage
+//	@Failure		412			{object}	types.APIErrorMessage
+//	@Failure		415			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/snippets/{id} [patch]
+func PatchSnippet(c *gin.Context) {
+	var patch types.PatchSnippetRequest
+
+	members, ok := bindMergePatch(c, &patch, "title", "code")
 	if !ok {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "unauthorized request"})
 		return
 	}
 
-	snippet, err := models.GetSnippetWithUser(uID, id)
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusBadRequest, types.APIErrorMessage{
-				ErrorMessage: "no snippet exists with the provided queries",
-			})
+		c.Error(apierror.New(apierror.CodeInvalidID, "invalid snippet ID"))
+		return
+	}
+
+	user, ok := getActiveUser(c)
+	if !ok {
+		return
+	}
+
+	snippet, apiErr := getOwnedSnippet(stores.Snippets, id, user.ID, "update")
+	if apiErr != nil {
+		c.Error(apiErr)
+		return
+	}
+
+	if !checkIfMatch(c, snippet) {
+		return
+	}
+
+	previous := snippet
+	if apiErr := applySnippetPatch(&snippet, members, patch); apiErr != nil {
+		c.Error(apiErr)
+		return
+	}
+	if apiErr := checkSnippetWrite(stores.Snippets, snippet, &previous); apiErr != nil {
+		c.Error(apiErr)
+		return
+	}
+
+	err = stores.Snippets.Save(&snippet)
+	if errors.Is(err, store.ErrConflict) {
+		c.Error(snippetConflict(c.GetHeader("If-Match") != "", err))
+		return
+	}
+	if err != nil {
+		c.Error(apierror.Internal(err, "unable to update snippet"))
+		return
+	}
+
+	audit.Record(c, user.ID, audit.SnippetUpdated, audit.TargetSnippet, snippet.ID.String())
+	publishSnippetEvent(events.SnippetUpdated, snippet)
+
+	c.Header("ETag", snippetETag(snippet))
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: toSnippetResponse(snippet),
+	})
+}
+
+// Render Snippet godoc
+//
+//	@Summary		Render a snippet
+//	@Description	Fill in the variables of a snippet, written {{name}} in its code, with the given values or their defaults. Values are checked against the type and constraints of each variable, and the rendered code can be no larger than a snippet. Nothing in the code is executed.
+//	@Tags			Snippets
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string						true	"Snippet ID"
+//	@Param			Values	body		types.RenderSnippetRequest	true	"values"
+//	@Success		200		{object}	types.APISuccessMessage{data=types.RenderSnippetResponse}
+//	@Failure		400		{object}	types.APIErrorMessage
+//	@Failure		404		{object}	types.APIErrorMessage
+//	@Failure		413		{object}	types.APIErrorMessage
+//	@Failure		500		{object}	types.APIErrorMessage
+//	@Router			/snippets/{id}/render [post]
+func RenderSnippet(c *gin.Context) {
+	var body types.RenderSnippetRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.New(apierror.CodeInvalidID, "invalid snippet ID"))
+		return
+	}
+
+	snippet, err := stores.Snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.Error(apierror.New(apierror.CodeSnippetNotFound, "no snippet exists with the provided ID"))
 			return
 		}
+		c.Error(apierror.Internal(err, "could not retrieve snippet"))
 		return
 	}
 
-	if snippet.UserID != uID {
-		c.JSON(http.StatusUnauthorized, types.APIErrorMessage{ErrorMessage: "you are not authorized to update this snippet"})
+	values := make(map[string]string, len(body.Values))
+	for name, value := range body.Values {
+		values[name] = string(value)
+	}
+	resolved, errs := placeholder.Resolve(snippet.Variables, values)
+	if len(errs) > 0 {
+		c.Error(apierror.Invalid(errs...))
 		return
 	}
 
-	err = snippet.Update(body.Title, body.Description, body.Code)
+	code, err := placeholder.Render(snippet.Code, resolved, config.ENV.SnippetMaxBytes)
+	if errors.Is(err, placeholder.ErrTooLarge) {
+		c.Error(apierror.New(apierror.CodeSnippetTooLarge,
+			"the rendered code would be larger than "+formatBytes(int64(config.ENV.SnippetMaxBytes))+"; use shorter values"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.APIErrorMessage{
-			ErrorMessage: "unable to delete snippet",
-		})
+		c.Error(apierror.Internal(err, "unable to render snippet"))
 		return
 	}
 
-	c.JSON(200, types.APISuccessMessage{
-		Data: "snippet updated",
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: types.RenderSnippetResponse{
+			Code:   code,
+			Values: resolved,
+		},
 	})
 }
+
+// newSnippetFromRequest builds a new snippet owned by user.
+func newSnippetFromRequest(user models.User, body types.NewSnippetRequest) (models.Snippet, *apierror.Error) {
+	tags, apiErr := normalizeTags(body.Tags)
+	if apiErr != nil {
+		return models.Snippet{}, apiErr
+	}
+	variables, apiErr := toSnippetVariables(body.Variables)
+	if apiErr != nil {
+		return models.Snippet{}, apiErr
+	}
+
+	snippet := models.Snippet{
+		Title:       body.Title,
+		Description: body.Description,
+		Code:        body.Code,
+		Trigger:     body.Trigger,
+		UserID:      user.ID,
+		User:        user,
+		ExpiresAt:   body.ExpiresAt,
+		Tags:        tags,
+		Variables:   variables,
+	}
+	snippet.ID = uuid.New()
+	return snippet, nil
+}
+
+// applySnippetUpdate overwrites the fields of a snippet that are set in an UpdateSnippetRequest.
+func applySnippetUpdate(snippet *models.Snippet, body types.UpdateSnippetRequest) *apierror.Error {
+	snippet.Update(body.Title, body.Description, body.Code)
+	if body.Trigger != "" {
+		snippet.Trigger = body.Trigger
+	}
+	if body.Tags != nil {
+		tags, apiErr := normalizeTags(*body.Tags)
+		if apiErr != nil {
+			return apiErr
+		}
+		snippet.Tags = tags
+	}
+	if body.Variables != nil {
+		variables, apiErr := toSnippetVariables(*body.Variables)
+		if apiErr != nil {
+			return apiErr
+		}
+		snippet.Variables = variables
+	}
+	return nil
+}
+
+// applySnippetPatch sets the fields of a snippet that are members of the patch. bindMergePatch has already
+// rejected null titles and code.
+func applySnippetPatch(snippet *models.Snippet, members map[string]json.RawMessage, patch types.PatchSnippetRequest) *apierror.Error {
+	if _, ok := members["title"]; ok {
+		snippet.Title = *patch.Title
+	}
+	if _, ok := members["code"]; ok {
+		snippet.Code = *patch.Code
+	}
+	if _, ok := members["description"]; ok {
+		snippet.Description = ""
+		if patch.Description != nil {
+			snippet.Description = *patch.Description
+		}
+	}
+	if _, ok := members["trigger"]; ok {
+		snippet.Trigger = ""
+		if patch.Trigger != nil {
+			snippet.Trigger = *patch.Trigger
+		}
+	}
+	if _, ok := members["expires_at"]; ok {
+		snippet.ExpiresAt = patch.ExpiresAt
+	}
+	if _, ok := members["tags"]; ok {
+		var tags []string
+		if patch.Tags != nil {
+			tags = *patch.Tags
+		}
+		normalized, apiErr := normalizeTags(tags)
+		if apiErr != nil {
+			return apiErr
+		}
+		snippet.Tags = normalized
+	}
+	if _, ok := members["variables"]; ok {
+		var requests []types.SnippetVariable
+		if patch.Variables != nil {
+			requests = *patch.Variables
+		}
+		variables, apiErr := toSnippetVariables(requests)
+		if apiErr != nil {
+			return apiErr
+		}
+		snippet.Variables = variables
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package controllers
+
+import "github.com/topboyasante/go-snip/internal/store"
+
+var stores *store.Store
+
+// SetStore injects the repositories used by every controller. It must be called before any route is served.
+func SetStore(s *store.Store) {
+	stores = s
+}
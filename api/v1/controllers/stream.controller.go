@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/events"
+	"golang.org/x/net/websocket"
+)
+
+// streamReset tells a resuming subscriber that events it missed are gone, and that it should fetch the snippets
+// it follows again.
+const streamReset = "stream.reset"
+
+// streamHeartbeat is how often an idle event stream sends a comment, so that proxies do not time it out.
+const streamHeartbeat = 25 * time.Second
+
+// Stream godoc
+//
+//	@Summary		Stream snippet changes
+//	@Description	Stream the snippet.created, snippet.updated and snippet.deleted events as server-sent events, whose data is a types.StreamEvent. Anonymous subscribers see the changes to snippets that anyone can see; signed-in users also see those to their own expired snippets, and admins see every change. Reconnecting with the Last-Event-ID header resumes the stream; if the events since then are no longer available, a stream.reset event is sent first, and the snippets should be fetched again.
+//	@Tags			Stream
+//	@Produce		text/event-stream
+//	@Security		ApiKeyAuth
+//	@Param			user			query		string	false	"Only changes to the snippets of this username"
+//	@Param			tag				query		string	false	"Only changes to snippets with this tag"
+//	@Param			snippet			query		string	false	"Only changes to the snippet with this ID"
+//	@Param			Last-Event-ID	header		string	false	"ID of the last event received"
+//	@Success		200				{object}	types.StreamEvent
+//	@Failure		400				{object}	types.APIErrorMessage
+//	@Failure		401				{object}	types.APIErrorMessage
+//	@Failure		404				{object}	types.APIErrorMessage
+//	@Router			/stream [get]
+func StreamEvents(c *gin.Context) {
+	sub, replay, ok := subscribeToStream(c, c.GetHeader("Last-Event-ID"))
+	if !ok {
+		return
+	}
+	defer sub.Cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	for _, event := range replay {
+		writeServerSentEvent(w, event)
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			io.WriteString(w, ": ping\n\n")
+		case event, ok := <-sub.C:
+			if !ok {
+				// The subscriber fell behind, and resumes when it reconnects
+				return
+			}
+			writeServerSentEvent(w, toStreamEvent(event))
+		}
+		w.Flush()
+	}
+}
+
+// Stream WebSocket godoc
+//
+//	@Summary		Stream snippet changes over WebSocket
+//	@Description	The same events as GET /stream, sent as JSON text messages over a WebSocket. Nothing is expected from the client. Since browsers cannot send a Last-Event-ID header on a WebSocket, the stream is resumed with the last_event_id query parameter instead.
+//	@Tags			Stream
+//	@Security		ApiKeyAuth
+//	@Param			user			query		string	false	"Only changes to the snippets of this username"
+//	@Param			tag				query		string	false	"Only changes to snippets with this tag"
+//	@Param			snippet			query		string	false	"Only changes to the snippet with this ID"
+//	@Param			last_event_id	query		string	false	"ID of the last event received"
+//	@Success		101				{object}	types.StreamEvent
+//	@Failure		400				{object}	types.APIErrorMessage
+//	@Failure		401				{object}	types.APIErrorMessage
+//	@Failure		404				{object}	types.APIErrorMessage
+//	@Router			/stream/ws [get]
+func StreamEventsWebSocket(c *gin.Context) {
+	sub, replay, ok := subscribeToStream(c, c.Query("last_event_id"))
+	if !ok {
+		return
+	}
+	defer sub.Cancel()
+
+	server := websocket.Server{
+		// Clients authenticate with a header rather than a cookie, so any origin may connect, as with CORS
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			// Reading is how a closed connection is noticed
+			closed := make(chan struct{})
+			go func() {
+				io.Copy(io.Discard, ws)
+				close(closed)
+			}()
+
+			for _, event := range replay {
+				if websocket.JSON.Send(ws, event) != nil {
+					return
+				}
+			}
+			for {
+				select {
+				case <-closed:
+					return
+				case event, ok := <-sub.C:
+					if !ok || websocket.JSON.Send(ws, toStreamEvent(event)) != nil {
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// subscribeToStream subscribes to the events that the query asks for and the signed-in user, if any, may see.
+// It returns the events to send before those of the subscription, which resume the stream after lastEventID.
+func subscribeToStream(c *gin.Context, lastEventID string) (*events.Subscription, []types.StreamEvent, bool) {
+	var query types.StreamQuery
+	if !bindQuery(c, &query) {
+		return nil, nil, false
+	}
+
+	viewerID, _ := getAuthenticatedUserID(c)
+	role, _ := c.Get("user_role")
+	admin := role == models.RoleAdmin
+
+	var ownerID uuid.UUID
+	if query.User != "" {
+		owner, err := stores.Users.GetByUsername(query.User)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			c.Error(apierror.Internal(err, "could not retrieve user"))
+			return nil, nil, false
+		}
+		// Users whose profile is hidden can still follow their own snippets
+		hidden := !owner.IsActive || owner.DeletionScheduledAt != nil
+		if owner.ID == uuid.Nil || (hidden && owner.ID != viewerID && !admin) {
+			c.Error(apierror.New(apierror.CodeUserNotFound, "user does not exist"))
+			return nil, nil, false
+		}
+		ownerID = owner.ID
+	}
+
+	var snippetID uuid.UUID
+	if query.Snippet != "" {
+		// Already validated by the uuid binding rule
+		snippetID = uuid.MustParse(query.Snippet)
+	}
+	tag := normalizeTag(query.Tag)
+
+	match := func(e events.Event) bool {
+		switch {
+		case ownerID != uuid.Nil && e.OwnerID != ownerID:
+			return false
+		case snippetID != uuid.Nil && e.SnippetID != snippetID:
+			return false
+		case tag != "" && !slices.Contains(e.Tags, tag):
+			return false
+		}
+		return e.Public || admin || (viewerID != uuid.Nil && e.OwnerID == viewerID)
+	}
+
+	var after uint64
+	if lastEventID != "" {
+		// IDs that cannot be parsed are as unknown as those the bus forgot
+		after, _ = strconv.ParseUint(lastEventID, 10, 64)
+		after = max(after, 1)
+	}
+
+	sub, missed, complete := events.Snippets.Subscribe(match, after)
+	if !complete {
+		return sub, []types.StreamEvent{{ID: strconv.FormatUint(sub.From, 10), Type: streamReset}}, true
+	}
+
+	replay := make([]types.StreamEvent, 0, len(missed))
+	for _, event := range missed {
+		replay = append(replay, toStreamEvent(event))
+	}
+	return sub, replay, true
+}
+
+func toStreamEvent(event events.Event) types.StreamEvent {
+	return types.StreamEvent{
+		ID:        strconv.FormatUint(event.ID, 10),
+		Type:      event.Type,
+		CreatedAt: &event.CreatedAt,
+		Data:      event.Data,
+	}
+}
+
+// writeServerSentEvent writes an event in the text/event-stream format. Encoded JSON never spans several lines,
+// so it fits in a single data field.
+func writeServerSentEvent(w io.Writer, event types.StreamEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		data = []byte("{}")
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/config"
+)
+
+// Get Usage godoc
+//
+//	@Summary		Get usage
+//	@Description	Get how many snippets the signed-in user has and how much code they take, against their quotas. Limits of zero are unlimited.
+//	@Tags			Users
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	types.APISuccessMessage{data=types.UsageResponse}
+//	@Failure		401	{object}	types.APIErrorMessage
+//	@Failure		500	{object}	types.APIErrorMessage
+//	@Router			/me/usage [get]
+func GetUsage(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "unauthorized request"))
+		return
+	}
+
+	usage, err := stores.Snippets.Usage(userID)
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve usage"))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: types.UsageResponse{
+			Snippets:        types.QuotaUsage{Used: usage.Snippets, Limit: int64(config.ENV.QuotaMaxSnippets)},
+			Bytes:           types.QuotaUsage{Used: usage.Bytes, Limit: int64(config.ENV.QuotaMaxBytes)},
+			MaxSnippetBytes: int64(config.ENV.SnippetMaxBytes),
+		},
+	})
+}
+
+// checkSnippetWrite checks the size of a snippet and the quotas of its owner before the snippet is stored.
+// previous is the snippet as it is stored, or nil for a new snippet.
+func checkSnippetWrite(snippets store.SnippetStore, snippet models.Snippet, previous *models.Snippet) *apierror.Error {
+	previousSize, newSnippets := 0, int64(1)
+	if previous != nil {
+		previousSize, newSnippets = len(previous.Code), 0
+	}
+
+	if apiErr := checkSnippetSize(snippet.Code, previousSize); apiErr != nil {
+		return apiErr
+	}
+	return checkQuota(snippets, snippet.UserID, newSnippets, int64(len(snippet.Code)-previousSize))
+}
+
+// checkSnippetSize returns an error if code is larger than snippets may have. Code that was already that large,
+// under an earlier limit, may stay so as long as it does not grow.
+func checkSnippetSize(code string, previousSize int) *apierror.Error {
+	limit := config.ENV.SnippetMaxBytes
+	if limit > 0 && len(code) > limit && len(code) > previousSize {
+		return apierror.New(apierror.CodeSnippetTooLarge, "the code of a snippet cannot be larger than "+formatBytes(int64(limit)))
+	}
+	return nil
+}
+
+// checkQuota returns an error if a user storing newSnippets more snippets and newBytes more bytes of code would
+// go over their quotas. Changes that do not add to what a user stores are always allowed, so that users over a
+// lowered quota can still edit and shrink their snippets.
+//
+// Quotas are checked before the write rather than in the same transaction, so concurrent writes by the same
+// user can each pass and together go over a quota by what they add. Quotas are meant to stop runaway usage,
+// not to be exact, and every write after that is refused.
+func checkQuota(snippets store.SnippetStore, userID uuid.UUID, newSnippets, newBytes int64) *apierror.Error {
+	if newSnippets <= 0 && newBytes <= 0 {
+		return nil
+	}
+
+	usage, err := snippets.Usage(userID)
+	if err != nil {
+		return apierror.Internal(err, "could not retrieve usage")
+	}
+
+	if limit := int64(config.ENV.QuotaMaxSnippets); limit > 0 && newSnippets > 0 && usage.Snippets+newSnippets > limit {
+		return apierror.New(apierror.CodeQuotaExceeded, fmt.Sprintf(
+			"you can have at most %d snippets, and already have %d; delete some first", limit, usage.Snippets))
+	}
+	if limit := int64(config.ENV.QuotaMaxBytes); limit > 0 && newBytes > 0 && usage.Bytes+newBytes > limit {
+		return apierror.New(apierror.CodeQuotaExceeded, fmt.Sprintf(
+			"your snippets can take at most %s, and already take %s; delete or shrink some first",
+			formatBytes(limit), formatBytes(usage.Bytes)))
+	}
+	return nil
+}
+
+// formatBytes writes a size for people, in bytes, KB or MB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return trimZero(float64(n)/(1<<20)) + " MB"
+	case n >= 1<<10:
+		return trimZero(float64(n)/(1<<10)) + " KB"
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+func trimZero(f float64) string {
+	if f == float64(int64(f)) {
+		return fmt.Sprintf("%d", int64(f))
+	}
+	return fmt.Sprintf("%.1f", f)
+}
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/audit"
+	"github.com/topboyasante/go-snip/pkg/exporter"
+)
+
+// Get User Profile godoc
+//
+//	@Summary		Get User Profile
+//	@Description	Get the public profile of a user
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	types.APISuccessMessage{data=types.UserProfileResponse}
+//	@Failure		404			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/users/{username} [get]
+func GetUserProfile(c *gin.Context) {
+	user, err := stores.Users.GetByUsername(c.Param("username"))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.Internal(err, "could not retrieve user"))
+		return
+	}
+
+	if user.ID == uuid.Nil || !user.IsActive || user.DeletionScheduledAt != nil {
+		c.Error(apierror.New(apierror.CodeUserNotFound, "user does not exist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: toUserProfileResponse(user),
+	})
+}
+
+// Get User Snippets godoc
+//
+//	@Summary		Get User Snippets
+//	@Description	Get a paginated list of a user's snippets
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Param			page		query		int		false	"Page number"	default(1)
+//	@Param			page_size	query		int		false	"Page size"		default(20)
+//	@Param			tag			query		string	false	"Only return snippets with this tag"
+//	@Success		200			{object}	types.APISuccessMessage{data=types.PaginatedResponse}
+//	@Failure		404			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/users/{username}/snippets [get]
+func GetUserSnippets(c *gin.Context) {
+	user, err := stores.Users.GetByUsername(c.Param("username"))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.Internal(err, "could not retrieve user"))
+		return
+	}
+
+	if user.ID == uuid.Nil || !user.IsActive || user.DeletionScheduledAt != nil {
+		c.Error(apierror.New(apierror.CodeUserNotFound, "user does not exist"))
+		return
+	}
+
+	page, pageSize := getPagination(c)
+
+	snippets, total, err := stores.Snippets.List(store.SnippetQuery{
+		UserID: user.ID,
+		Tag:    normalizeTag(c.Query("tag")),
+		Limit:  pageSize,
+		Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve snippets"))
+		return
+	}
+
+	items := make([]types.NewSnippetResponse, 0, len(snippets))
+	for _, snippet := range snippets {
+		items = append(items, toSnippetResponse(snippet))
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: types.PaginatedResponse{
+			Items:    items,
+			Page:     page,
+			PageSize: pageSize,
+			Total:    total,
+		},
+	})
+}
+
+// Get Me godoc
+//
+//	@Summary		Get Me
+//	@Description	Get the profile of the signed-in user
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	types.APISuccessMessage{data=types.MeResponse}
+//	@Failure		401	{object}	types.APIErrorMessage
+//	@Failure		500	{object}	types.APIErrorMessage
+//	@Router			/me [get]
+func GetMe(c *gin.Context) {
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: toMeResponse(user),
+	})
+}
+
+// Update Me godoc
+//
+//	@Summary		Update Me
+//	@Description	Update the profile of the signed-in user
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			Profile	body		types.UpdateProfileRequest	true	"profile"
+//	@Success		200		{object}	types.APISuccessMessage{data=types.MeResponse}
+//	@Failure		400		{object}	types.APIErrorMessage
+//	@Failure		401		{object}	types.APIErrorMessage
+//	@Failure		500		{object}	types.APIErrorMessage
+//	@Router			/me [patch]
+func UpdateMe(c *gin.Context) {
+	var body types.UpdateProfileRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	user, ok := getAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	// Only overwrite the fields that were sent
+	displayName, bio, avatarURL, website := user.DisplayName, user.Bio, user.AvatarURL, user.Website
+	if body.DisplayName != nil {
+		displayName = *body.DisplayName
+	}
+	if body.Bio != nil {
+		bio = *body.Bio
+	}
+	if body.AvatarURL != nil {
+		avatarURL = *body.AvatarURL
+	}
+	if body.Website != nil {
+		website = *body.Website
+	}
+
+	user.UpdateProfile(displayName, bio, avatarURL, website)
+	if body.Locale != nil {
+		user.Locale = *body.Locale
+	}
+	if err := stores.Users.Save(&user); err != nil {
+		c.Error(apierror.Internal(err, "unable to update profile"))
+		return
+	}
+
+	audit.Record(c, user.ID, audit.ProfileUpdated, audit.TargetUser, user.ID.String())
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		SuccessMessage: "profile updated",
+		Data:           toMeResponse(user),
+	})
+}
+
+// Export User Snippets godoc
+//
+//	@Summary		Export User Snippets
+//	@Description	Download a user's snippets in the format of an editor or text expander: vscode (.code-snippets JSON), sublime (a zip of .sublime-snippet files), ultisnips (Vim), espanso (YAML) or jetbrains (live template XML). Tab stops in the code are translated to the syntax of the format.
+//	@Tags			Users
+//	@Produce		octet-stream
+//	@Param			username	path		string	true	"Username"
+//	@Param			format		query		string	true	"Format of the file"
+//	@Param			tag			query		string	false	"Only export snippets with this tag"
+//	@Success		200			{file}		file
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		404			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/users/{username}/snippets/export [get]
+func ExportUserSnippets(c *gin.Context) {
+	var query types.ExportSnippetsQuery
+
+	if !bindQuery(c, &query) {
+		return
+	}
+	format, ok := exporter.Lookup(query.Format)
+	if !ok {
+		c.Error(apierror.Invalid(apierror.FieldError{
+			Field:   "format",
+			Message: "must be one of " + strings.Join(exporter.Formats(), ", "),
+		}))
+		return
+	}
+
+	user, err := stores.Users.GetByUsername(c.Param("username"))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.Internal(err, "could not retrieve user"))
+		return
+	}
+
+	if user.ID == uuid.Nil || !user.IsActive || user.DeletionScheduledAt != nil {
+		c.Error(apierror.New(apierror.CodeUserNotFound, "user does not exist"))
+		return
+	}
+
+	snippets, _, err := stores.Snippets.List(store.SnippetQuery{
+		UserID: user.ID,
+		Tag:    normalizeTag(query.Tag),
+	})
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve snippets"))
+		return
+	}
+
+	// Render before writing anything, so that a failure can still be reported as an error
+	var buf bytes.Buffer
+	if err := format.Write(&buf, snippets); err != nil {
+		c.Error(apierror.Internal(err, "could not export snippets"))
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": user.Username + format.Extension,
+	}))
+	c.Data(http.StatusOK, format.ContentType, buf.Bytes())
+}
@@ -0,0 +1,374 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/audit"
+	"github.com/topboyasante/go-snip/pkg/webhook"
+)
+
+// maxWebhooks is the number of webhooks a user can have.
+const maxWebhooks = 20
+
+// List Webhooks godoc
+//
+//	@Summary		List webhooks
+//	@Description	List the webhooks of the signed-in user
+//	@Tags			Webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	types.APISuccessMessage{data=[]types.WebhookResponse}
+//	@Failure		401	{object}	types.APIErrorMessage
+//	@Failure		500	{object}	types.APIErrorMessage
+//	@Router			/me/webhooks [get]
+func ListWebhooks(c *gin.Context) {
+	uID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "unauthorized request"))
+		return
+	}
+
+	webhooks, err := models.ListUserWebhooks(uID)
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve webhooks"))
+		return
+	}
+
+	items := make([]types.WebhookResponse, 0, len(webhooks))
+	for _, w := range webhooks {
+		items = append(items, toWebhookResponse(w))
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: items,
+	})
+}
+
+// Create Webhook godoc
+//
+//	@Summary		Create a webhook
+//	@Description	Subscribe a URL to events on the snippets of the signed-in user: snippet.created, snippet.updated and snippet.deleted. The URL must resolve to a public address. Every event is POSTed as JSON, with its type in the X-Snip-Event header and the ID of the delivery in X-Snip-Delivery. X-Snip-Signature holds "sha256=" and the hex HMAC-SHA256, keyed with the secret of the webhook, of the X-Snip-Timestamp header, a dot and the body. The secret is only returned by this request. Failed deliveries are retried with exponential backoff, and webhooks that keep failing are disabled.
+//	@Tags			Webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			Webhook	body		types.NewWebhookRequest	true	"webhook"
+//	@Success		201		{object}	types.APISuccessMessage{data=types.WebhookResponse}
+//	@Failure		400		{object}	types.APIErrorMessage
+//	@Failure		401		{object}	types.APIErrorMessage
+//	@Failure		409		{object}	types.APIErrorMessage
+//	@Failure		500		{object}	types.APIErrorMessage
+//	@Router			/me/webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	var body types.NewWebhookRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	uID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "unauthorized request"))
+		return
+	}
+
+	if !checkWebhookURL(c, body.URL) {
+		return
+	}
+
+	existing, err := models.ListUserWebhooks(uID)
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve webhooks"))
+		return
+	}
+	if len(existing) >= maxWebhooks {
+		c.Error(apierror.New(apierror.CodeInvalidState, fmt.Sprintf("you can have at most %d webhooks", maxWebhooks)))
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		c.Error(apierror.Internal(err, "unable to create webhook"))
+		return
+	}
+
+	w := models.Webhook{
+		ID:     uuid.New(),
+		UserID: uID,
+		URL:    body.URL,
+		Secret: secret,
+		Events: uniqueEvents(body.Events),
+		Active: true,
+	}
+	if err := w.Create(); err != nil {
+		c.Error(apierror.Internal(err, "unable to create webhook"))
+		return
+	}
+
+	audit.Record(c, uID, audit.WebhookCreated, audit.TargetWebhook, w.ID.String())
+
+	res := toWebhookResponse(w)
+	res.Secret = w.Secret
+	c.JSON(http.StatusCreated, types.APISuccessMessage{
+		Data: res,
+	})
+}
+
+// Get Webhook godoc
+//
+//	@Summary		Get a webhook
+//	@Description	Get a webhook of the signed-in user
+//	@Tags			Webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id	path		string	true	"Webhook ID"
+//	@Success		200	{object}	types.APISuccessMessage{data=types.WebhookResponse}
+//	@Failure		400	{object}	types.APIErrorMessage
+//	@Failure		401	{object}	types.APIErrorMessage
+//	@Failure		404	{object}	types.APIErrorMessage
+//	@Failure		500	{object}	types.APIErrorMessage
+//	@Router			/me/webhooks/{id} [get]
+func GetWebhook(c *gin.Context) {
+	w, ok := getOwnedWebhook(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: toWebhookResponse(w),
+	})
+}
+
+// Update Webhook godoc
+//
+//	@Summary		Update a webhook
+//	@Description	Change the URL or events of a webhook, or disable it. Enabling a webhook that was disabled for failing resets its count of failures; deliveries that were given up on can then be redelivered.
+//	@Tags			Webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id		path		string						true	"Webhook ID"
+//	@Param			Webhook	body		types.UpdateWebhookRequest	true	"webhook"
+//	@Success		200		{object}	types.APISuccessMessage{data=types.WebhookResponse}
+//	@Failure		400		{object}	types.APIErrorMessage
+//	@Failure		401		{object}	types.APIErrorMessage
+//	@Failure		404		{object}	types.APIErrorMessage
+//	@Failure		500		{object}	types.APIErrorMessage
+//	@Router			/me/webhooks/{id} [patch]
+func UpdateWebhook(c *gin.Context) {
+	var body types.UpdateWebhookRequest
+
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	w, ok := getOwnedWebhook(c)
+	if !ok {
+		return
+	}
+
+	if body.URL != nil {
+		if !checkWebhookURL(c, *body.URL) {
+			return
+		}
+		w.URL = *body.URL
+	}
+	if body.Events != nil {
+		w.Events = uniqueEvents(*body.Events)
+	}
+	if body.Active != nil && *body.Active != w.Active {
+		w.Active = *body.Active
+		if w.Active {
+			w.ConsecutiveFailures = 0
+			w.DisabledAt = nil
+		}
+	}
+
+	if err := w.Save(); err != nil {
+		c.Error(apierror.Internal(err, "unable to update webhook"))
+		return
+	}
+
+	audit.Record(c, w.UserID, audit.WebhookUpdated, audit.TargetWebhook, w.ID.String())
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: toWebhookResponse(w),
+	})
+}
+
+// Delete Webhook godoc
+//
+//	@Summary		Delete a webhook
+//	@Description	Delete a webhook along with its delivery log. Pending deliveries are not sent.
+//	@Tags			Webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id	path		string	true	"Webhook ID"
+//	@Success		200	{object}	types.APISuccessMessage
+//	@Failure		400	{object}	types.APIErrorMessage
+//	@Failure		401	{object}	types.APIErrorMessage
+//	@Failure		404	{object}	types.APIErrorMessage
+//	@Failure		500	{object}	types.APIErrorMessage
+//	@Router			/me/webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	w, ok := getOwnedWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := w.Delete(); err != nil {
+		c.Error(apierror.Internal(err, "unable to delete webhook"))
+		return
+	}
+
+	audit.Record(c, w.UserID, audit.WebhookDeleted, audit.TargetWebhook, w.ID.String())
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: "webhook deleted",
+	})
+}
+
+// List Webhook Deliveries godoc
+//
+//	@Summary		List webhook deliveries
+//	@Description	Get the delivery log of a webhook, newest first, with the outcome of the last attempt at every delivery
+//	@Tags			Webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id			path		string	true	"Webhook ID"
+//	@Param			page		query		int		false	"Page number"	default(1)
+//	@Param			page_size	query		int		false	"Page size"		default(20)
+//	@Success		200			{object}	types.APISuccessMessage{data=types.PaginatedResponse{items=[]types.WebhookDeliveryResponse}}
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		401			{object}	types.APIErrorMessage
+//	@Failure		404			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/me/webhooks/{id}/deliveries [get]
+func ListWebhookDeliveries(c *gin.Context) {
+	w, ok := getOwnedWebhook(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := getPagination(c)
+	deliveries, total, err := models.ListWebhookDeliveries(w.ID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve deliveries"))
+		return
+	}
+
+	items := make([]types.WebhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		items = append(items, toWebhookDeliveryResponse(delivery))
+	}
+
+	c.JSON(http.StatusOK, types.APISuccessMessage{
+		Data: types.PaginatedResponse{
+			Items:    items,
+			Page:     page,
+			PageSize: pageSize,
+			Total:    total,
+		},
+	})
+}
+
+// Redeliver Webhook Delivery godoc
+//
+//	@Summary		Redeliver a webhook delivery
+//	@Description	Send a delivery again, as a new delivery with the same payload, whether or not it was delivered. The event keeps its ID, so that receivers can tell it was already sent.
+//	@Tags			Webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			id			path		string	true	"Webhook ID"
+//	@Param			delivery_id	path		string	true	"Delivery ID"
+//	@Success		202			{object}	types.APISuccessMessage{data=types.WebhookDeliveryResponse}
+//	@Failure		400			{object}	types.APIErrorMessage
+//	@Failure		401			{object}	types.APIErrorMessage
+//	@Failure		404			{object}	types.APIErrorMessage
+//	@Failure		409			{object}	types.APIErrorMessage
+//	@Failure		500			{object}	types.APIErrorMessage
+//	@Router			/me/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func RedeliverWebhookDelivery(c *gin.Context) {
+	w, ok := getOwnedWebhook(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("delivery_id"))
+	if err != nil {
+		c.Error(apierror.New(apierror.CodeInvalidID, "invalid delivery ID"))
+		return
+	}
+
+	original, found, err := models.GetWebhookDelivery(w.ID, id)
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve delivery"))
+		return
+	}
+	if !found {
+		c.Error(apierror.New(apierror.CodeDeliveryNotFound, "no delivery exists with the provided ID"))
+		return
+	}
+
+	if !w.Active {
+		c.Error(apierror.New(apierror.CodeInvalidState, "webhook is disabled, enable it to redeliver"))
+		return
+	}
+
+	delivery, err := webhook.Redeliver(original)
+	if err != nil {
+		c.Error(apierror.Internal(err, "unable to redeliver"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, types.APISuccessMessage{
+		Data: toWebhookDeliveryResponse(delivery),
+	})
+}
+
+// checkWebhookURL rejects URLs that do not point to a public address, returning false if it did.
+func checkWebhookURL(c *gin.Context, rawURL string) bool {
+	if err := webhook.CheckURL(c.Request.Context(), rawURL); err != nil {
+		c.Error(apierror.New(apierror.CodeValidation, err.Error()))
+		return false
+	}
+	return true
+}
+
+// getOwnedWebhook loads the webhook in the id path parameter, which must belong to the signed-in user, writing an
+// error response and returning false if that fails. Webhooks of other users are reported as not found.
+func getOwnedWebhook(c *gin.Context) (models.Webhook, bool) {
+	uID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "unauthorized request"))
+		return models.Webhook{}, false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.New(apierror.CodeInvalidID, "invalid webhook ID"))
+		return models.Webhook{}, false
+	}
+
+	w, found, err := models.GetUserWebhook(uID, id)
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve webhook"))
+		return models.Webhook{}, false
+	}
+	if !found {
+		c.Error(apierror.New(apierror.CodeWebhookNotFound, "no webhook exists with the provided ID"))
+		return models.Webhook{}, false
+	}
+	return w, true
+}
@@ -1,63 +1,89 @@
 package middleware
 
 import (
-	"fmt"
-	"log"
-	"net/http"
+	"errors"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 	"github.com/topboyasante/go-snip/api/v1/models"
-	"github.com/topboyasante/go-snip/internal/database"
-	"github.com/topboyasante/go-snip/pkg/config"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/auth"
 )
 
+var stores *store.Store
+
+// SetStore injects the repositories used by the middleware. It must be called before any route is served.
+func SetStore(s *store.Store) {
+	stores = s
+}
+
 func RequireAuth(c *gin.Context) {
 	// Get accessToken from Header
 	tokenStr := c.GetHeader("Authorization")
 
 	// Return if no accessToken was provided
 	if tokenStr == "" {
-		c.AbortWithStatus(http.StatusUnauthorized)
+		c.Error(apierror.New(apierror.CodeUnauthorized, "missing access token"))
+		c.Abort()
 		return
 	}
 
-	tokenStr = strings.TrimPrefix(tokenStr, "Bearer ")
+	authenticate(c, tokenStr)
+}
 
-	// Parse the accessToken and check if the correct signing method was used
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
+// OptionalAuth is RequireAuth for routes that anyone may use, but that show more to signed-in users. Requests
+// without an access token go through anonymously; those with an invalid one are still rejected.
+func OptionalAuth(c *gin.Context) {
+	tokenStr := c.GetHeader("Authorization")
+	if tokenStr == "" {
+		c.Next()
+		return
+	}
 
-		return []byte(config.ENV.JWTKey), nil
-	})
+	authenticate(c, tokenStr)
+}
 
-	if err != nil {
-		log.Println(err)
+// authenticate signs in the user of an Authorization header, or aborts the request.
+func authenticate(c *gin.Context, tokenStr string) {
+	tokenStr = strings.TrimPrefix(tokenStr, "Bearer ")
 
-		c.AbortWithStatus(http.StatusUnauthorized)
+	// Parse the accessToken and check if the correct signing method was used
+	userID, tokenVersion, err := auth.ParseJWTToken(tokenStr, auth.AccessToken)
+	if err != nil {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "invalid or expired access token"))
+		c.Abort()
+		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.AbortWithStatus(http.StatusUnauthorized)
-		}
-
-		var user models.User
-		database.DB.Where("id = ?", claims["sub"]).First(&user)
+	user, err := stores.Users.GetByID(userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "the account of this access token no longer exists"))
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.Error(apierror.Internal(err, "could not retrieve user"))
+		c.Abort()
+		return
+	}
+	if tokenVersion != user.TokenVersion {
+		c.Error(apierror.New(apierror.CodeUnauthorized, "this access token has been revoked, please sign in again"))
+		c.Abort()
+		return
+	}
 
-		if user.ID == uuid.Nil {
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
-		}
+	c.Set("user_id", user.ID)
+	c.Set("user_role", user.Role)
+	c.Next()
+}
 
-		c.Set("user_id", user.ID)
-	} else {
-		c.AbortWithStatus(http.StatusUnauthorized)
+// RequireAdmin must run after RequireAuth, and only lets admins through.
+func RequireAdmin(c *gin.Context) {
+	if role, _ := c.Get("user_role"); role != models.RoleAdmin {
+		c.Error(apierror.New(apierror.CodeForbidden, "admin access required"))
+		c.Abort()
+		return
 	}
 	c.Next()
 }
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+)
+
+// LimitRequestBody rejects request bodies larger than max bytes, so that no handler reads more than that into
+// memory. Bodies that announce their length are rejected up front; others fail once they are read past max.
+// Zero disables the limit.
+func LimitRequestBody(max int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if max <= 0 {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > max {
+			c.Error(apierror.New(apierror.CodeRequestTooLarge, fmt.Sprintf("request bodies cannot be larger than %d bytes", max)))
+			c.Abort()
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+		}
+		c.Next()
+	}
+}
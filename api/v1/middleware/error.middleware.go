@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// RequestID tags every request with an ID, kept from the X-Request-ID header when the client or a proxy sent a
+// sane one, and echoes it in the response so that errors can be matched to log lines.
+func RequestID(c *gin.Context) {
+	requestID := c.GetHeader(requestIDHeader)
+	if requestID == "" || len(requestID) > 64 {
+		requestID = uuid.NewString()
+	}
+
+	c.Set("request_id", requestID)
+	c.Header(requestIDHeader, requestID)
+	c.Next()
+}
+
+// ErrorHandler renders the last error a handler added with c.Error, unless the handler already responded.
+// It must run after RequestID.
+func ErrorHandler(c *gin.Context) {
+	c.Next()
+
+	if len(c.Errors) == 0 {
+		return
+	}
+
+	err := apierror.From(c.Errors.Last().Err)
+	requestID := c.GetString("request_id")
+	if err.Err != nil || err.Status() >= 500 {
+		log.Printf("request %s: %s %s: %v", requestID, c.Request.Method, c.Request.URL.Path, err)
+	}
+
+	// A streamed response, like an export, can fail after its headers were sent
+	if c.Writer.Written() {
+		return
+	}
+
+	render(c, err)
+}
+
+// Recovery renders a panic as an internal error, for use with gin.CustomRecovery.
+func Recovery(c *gin.Context, recovered any) {
+	log.Printf("request %s: %s %s: panic: %v", c.GetString("request_id"), c.Request.Method, c.Request.URL.Path, recovered)
+	render(c, apierror.New(apierror.CodeInternal, "internal server error"))
+}
+
+// NoRoute answers requests for unknown routes in the same format as every other error.
+func NoRoute(c *gin.Context) {
+	c.Error(apierror.New(apierror.CodeNotFound, "no route matches "+c.Request.Method+" "+c.Request.URL.Path))
+}
+
+func render(c *gin.Context, err *apierror.Error) {
+	c.AbortWithStatusJSON(err.Status(), types.APIErrorMessage{
+		ErrorMessage: err.Message,
+		Code:         err.Code,
+		Details:      err.Fields,
+		RequestID:    c.GetString("request_id"),
+	})
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/ratelimit"
+)
+
+// KeyFunc identifies who a request counts against.
+type KeyFunc func(c *gin.Context) string
+
+// ByIP counts requests against the IP address of the client.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser counts requests against the signed-in user, so it must run after RequireAuth or OptionalAuth.
+// Anonymous requests count against their IP address.
+func ByUser(c *gin.Context) string {
+	if userID, ok := c.Get("user_id"); ok {
+		if id, ok := userID.(uuid.UUID); ok {
+			return "user:" + id.String()
+		}
+	}
+	return ByIP(c)
+}
+
+// ByToken counts requests against their access token, so that every session of a user has its own limit.
+// Requests without one count against their IP address.
+func ByToken(c *gin.Context) string {
+	tokenStr := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenStr == "" {
+		return ByIP(c)
+	}
+	sum := sha256.Sum256([]byte(tokenStr))
+	return "token:" + hex.EncodeToString(sum[:])
+}
+
+// RateLimit limits the requests of every key to spec, written as "requests/period" (see ratelimit.ParseLimit).
+// name tells the buckets of different route groups apart. An empty spec disables the limit, and an invalid one
+// stops the server from starting.
+//
+// Responses carry the RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and
+// requests over the limit are rejected with a 429 and a Retry-After header. If the buckets cannot be reached,
+// requests are let through rather than failing.
+func RateLimit(name, spec string, key KeyFunc) gin.HandlerFunc {
+	limit, err := ratelimit.ParseLimit(spec)
+	if err != nil {
+		log.Fatalf("invalid %s rate limit %q: %v", name, spec, err)
+	}
+	if limit.IsZero() {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	limiter := &ratelimit.Limiter{Name: name, Limit: limit, Store: ratelimit.DefaultStore}
+	policy := fmt.Sprintf("%d;w=%d", limit.Burst, int(math.Ceil(limit.Period.Seconds())))
+
+	return func(c *gin.Context) {
+		result, err := limiter.Allow(key(c), time.Now())
+		if err != nil {
+			log.Printf("failed to check %s rate limit: %v", name, err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+			c.Error(apierror.New(apierror.CodeRateLimited, "too many requests, please try again later"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds rounds a duration up to whole seconds, as the headers expect.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
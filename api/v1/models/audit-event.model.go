@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/internal/database"
+)
+
+type AuditEvent struct {
+	ID        uuid.UUID  `json:"id" gorm:"primarykey; type:uuid;unique;"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index"`
+	ActorID   *uuid.UUID `json:"actor_id" gorm:"type:uuid;index"` // nil when nobody was signed in, e.g. a failed sign-in
+	Action    string     `json:"action" gorm:"index"`
+	// TargetType and TargetID identify what the action was performed on, e.g. "snippet" and its ID
+	TargetType string `json:"target_type" gorm:"index:idx_audit_events_target"`
+	TargetID   string `json:"target_id" gorm:"index:idx_audit_events_target"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+}
+
+// AuditEventFilter narrows down ListAuditEvents. Zero values are ignored.
+type AuditEventFilter struct {
+	ActorID    *uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	From       time.Time
+	To         time.Time
+	// Involving matches events that were either performed by or performed on the user
+	Involving *uuid.UUID
+}
+
+func (event *AuditEvent) Create() error {
+	return database.DB.Create(event).Error
+}
+
+// ListAuditEvents returns one page of matching events, newest first, along with the total count.
+func ListAuditEvents(filter AuditEventFilter, limit, offset int) ([]AuditEvent, int64, error) {
+	var events []AuditEvent
+	var total int64
+
+	query := database.DB.Model(&AuditEvent{})
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Involving != nil {
+		query = query.Where("actor_id = ? OR (target_type = ? AND target_id = ?)", *filter.Involving, "user", filter.Involving.String())
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return []AuditEvent{}, 0, err
+	}
+
+	err := query.Order("created_at desc").Limit(limit).Offset(offset).Find(&events).Error
+	if err != nil {
+		return []AuditEvent{}, 0, err
+	}
+	return events, total, nil
+}
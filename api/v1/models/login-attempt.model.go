@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/topboyasante/go-snip/internal/database"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttempt backs lockout.DatabaseStore. Key is an account or IP address, prefixed with its kind. Version
+// changes on every update, so that concurrent failures cannot overwrite each other.
+type LoginAttempt struct {
+	Key           string    `json:"key" gorm:"primarykey"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Version       int64     `json:"version"`
+}
+
+func GetLoginAttempt(key string) (LoginAttempt, bool, error) {
+	var attempt LoginAttempt
+	result := database.DB.Where("key = ?", key).Limit(1).Find(&attempt)
+	if result.Error != nil {
+		return LoginAttempt{}, false, result.Error
+	}
+	return attempt, result.RowsAffected == 1, nil
+}
+
+// Create inserts the attempt, and reports false if another request created it first.
+func (attempt *LoginAttempt) Create() (bool, error) {
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(attempt)
+	return result.RowsAffected == 1, result.Error
+}
+
+// SaveIfVersion updates the attempt if it is still at version, and reports false if another request updated it first.
+func (attempt *LoginAttempt) SaveIfVersion(version int64) (bool, error) {
+	result := database.DB.Model(&LoginAttempt{}).
+		Where("key = ? AND version = ?", attempt.Key, version).
+		Updates(map[string]any{
+			"failures":        attempt.Failures,
+			"last_failure_at": attempt.LastFailureAt,
+			"locked_until":    attempt.LockedUntil,
+			"version":         attempt.Version,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func DeleteLoginAttempt(key string) error {
+	return database.DB.Where("key = ?", key).Delete(&LoginAttempt{}).Error
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/internal/database"
+)
+
+// OutboxMessage is an email waiting to be sent by email.Outbox, or the record of one that was sent or given up on.
+type OutboxMessage struct {
+	ID         uuid.UUID `json:"id" gorm:"primarykey;type:uuid"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Recipients []string  `json:"recipients" gorm:"serializer:json;not null"`
+	Subject    string    `json:"subject"`
+	HTML       string    `json:"html" gorm:"column:html"`
+	Text       string    `json:"text" gorm:"column:text_body"`
+	// Attempts counts the failed attempts to send the message
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	// FailedAt is set when the outbox gives up on the message
+	FailedAt *time.Time `json:"failed_at"`
+}
+
+func (message *OutboxMessage) Create() error {
+	return database.DB.Create(message).Error
+}
+
+func (message *OutboxMessage) Save() error {
+	return database.DB.Save(message).Error
+}
+
+// DeleteFinishedOutboxMessages removes the messages that were sent or given up on before the given time, and
+// returns how many it removed.
+func DeleteFinishedOutboxMessages(before time.Time) (int64, error) {
+	result := database.DB.
+		Where("sent_at < ? OR failed_at < ?", before, before).
+		Delete(&OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// ClaimDueOutboxMessages returns up to limit messages whose next attempt is due, oldest first, and pushes their
+// next attempt back by lease, so that other instances do not send them too while they are being sent.
+func ClaimDueOutboxMessages(now time.Time, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	var due []OutboxMessage
+	err := database.DB.
+		Where("sent_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&due).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := due[:0]
+	for _, message := range due {
+		// Another instance may have claimed the message since it was read
+		result := database.DB.Model(&OutboxMessage{}).
+			Where("id = ? AND sent_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", message.ID, now).
+			Update("next_attempt_at", now.Add(lease))
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			message.NextAttemptAt = now.Add(lease)
+			claimed = append(claimed, message)
+		}
+	}
+	return claimed, nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/topboyasante/go-snip/internal/database"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitBucket backs ratelimit.DatabaseStore. Key is the name of a limit and who it applies to. Version
+// changes on every update, so that concurrent requests cannot both take the same token.
+type RateLimitBucket struct {
+	Key       string    `json:"key" gorm:"primarykey"`
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime:false"`
+	// ExpiresAt is when the bucket is full again if left alone, after which it can be forgotten
+	ExpiresAt time.Time `json:"expires_at"`
+	Version   int64     `json:"version"`
+}
+
+func GetRateLimitBucket(key string) (RateLimitBucket, bool, error) {
+	var bucket RateLimitBucket
+	result := database.DB.Where("key = ?", key).Limit(1).Find(&bucket)
+	if result.Error != nil {
+		return RateLimitBucket{}, false, result.Error
+	}
+	return bucket, result.RowsAffected == 1, nil
+}
+
+// Create inserts the bucket, and reports false if another request created it first.
+func (bucket *RateLimitBucket) Create() (bool, error) {
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(bucket)
+	return result.RowsAffected == 1, result.Error
+}
+
+// SaveIfVersion updates the bucket if it is still at version, and reports false if another request updated it first.
+func (bucket *RateLimitBucket) SaveIfVersion(version int64) (bool, error) {
+	result := database.DB.Model(&RateLimitBucket{}).
+		Where("key = ? AND version = ?", bucket.Key, version).
+		Updates(map[string]any{
+			"tokens":     bucket.Tokens,
+			"updated_at": bucket.UpdatedAt,
+			"expires_at": bucket.ExpiresAt,
+			"version":    bucket.Version,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func DeleteExpiredRateLimitBuckets(now time.Time) error {
+	return database.DB.Where("expires_at <= ?", now).Delete(&RateLimitBucket{}).Error
+}
@@ -0,0 +1,9 @@
+package models
+
+import "github.com/google/uuid"
+
+// SnippetTag is a row of the snippet_tags join table. Snippets expose their tags as Snippet.Tags.
+type SnippetTag struct {
+	SnippetID uuid.UUID `gorm:"primaryKey;type:uuid"`
+	Tag       string    `gorm:"primaryKey"`
+}
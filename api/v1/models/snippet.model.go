@@ -1,79 +1,68 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
-	"github.com/topboyasante/go-snip/internal/database"
 )
 
 type Snippet struct {
 	BaseModel
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Code        string    `json:"code"`
-	UserID      uuid.UUID `json:"user_id"`
-	User        User      `json:"user" gorm:"foreignKey:UserID"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Code may contain tab stops for editors, which the exporter translates into each editor's syntax:
+	// $1 or ${1} is a tab stop, ${1:default} one with default text and $0 the final cursor position. \$ is a
+	// literal dollar sign, and \} a literal brace in default text.
+	Code string `json:"code"`
+	// Trigger is the word that expands the snippet in an editor. Exports fall back to a slug of the title.
+	Trigger string `json:"trigger"`
+	// Variables are filled in where Code has {{name}} when the snippet is rendered
+	Variables []SnippetVariable `json:"variables" gorm:"serializer:json;not null"`
+	UserID    uuid.UUID         `json:"user_id"`
+	User      User              `json:"user" gorm:"foreignKey:UserID"`
+	// ExpiresAt hides the snippet once it has passed, until it is purged. Nil snippets never expire.
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Tags are stored in the snippet_tags table by the store, and always come back in alphabetical order
+	Tags []string `json:"tags" gorm:"-"`
+	// Version is bumped by the store on every save, and is the ETag of the snippet
+	Version int64 `json:"version" gorm:"not null;default:1"`
 }
 
-func (snippet *Snippet) Create() (*Snippet, error) {
-	err := database.DB.Create(&snippet).Error
-	if err != nil {
-		return &Snippet{}, err
-	}
-	return snippet, nil
-}
+// Types of a SnippetVariable
+const (
+	VariableString = "string"
+	VariableInt    = "int"
+	VariableBool   = "bool"
+	VariableEnum   = "enum"
+)
 
-func GetSnippets() ([]Snippet, error) {
-	var snippets []Snippet
-	err := database.DB.Preload("User").Find(&snippets).Error
-	if err != nil {
-		return []Snippet{}, err
-	}
-	return snippets, nil
+// SnippetVariable is a blank in the code of a snippet. Values are checked against its type and constraints:
+// Pattern, and Min and Max as lengths, for strings; Min and Max as bounds for ints; and Options for enums.
+// A variable without a default must be given a value.
+type SnippetVariable struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Default     *string  `json:"default,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Min         *int     `json:"min,omitempty"`
+	Max         *int     `json:"max,omitempty"`
+	Options     []string `json:"options,omitempty"`
 }
 
-func GetSnippetsWithUser(userId uuid.UUID) ([]Snippet, error) {
-	var snippets []Snippet
-	err := database.DB.Preload("User").Where("user_id = ?", userId).Find(&snippets).Error
-	if err != nil {
-		return []Snippet{}, err
-	}
-	return snippets, nil
+func (snippet *Snippet) IsExpired(now time.Time) bool {
+	return snippet.ExpiresAt != nil && !snippet.ExpiresAt.After(now)
 }
 
-func GetSnippet(snippetId string) (Snippet, error) {
-	var snippet Snippet
-	err :=  database.DB.Preload("User").Where("id = ?", snippetId).Find(&snippet).Error
-	if err != nil {
-		return Snippet{}, err
+// Update overwrites the fields that are not empty, leaving the rest untouched.
+func (snippet *Snippet) Update(title, description, code string) {
+	if title != "" {
+		snippet.Title = title
 	}
-	return snippet, nil
-}
-
-func GetSnippetWithUser(userId uuid.UUID, snippetId string) (Snippet, error) {
-	var snippet Snippet
-	err :=  database.DB.Preload("User").Where("user_id = ?", userId).Where("id = ?", snippetId).Find(&snippet).Error
-	if err != nil {
-		return Snippet{}, err
-	}
-	return snippet, nil
-}
-
-func (snippet *Snippet) Delete() error {
-	err := database.DB.Where("id = ?", snippet.ID).Delete(&snippet).Error
-	if err != nil {
-		return err
+	if description != "" {
+		snippet.Description = description
 	}
-	return nil
-}
-
-func (snippet *Snippet) Update(title, description, code string) error {
-	err := database.DB.Model(&snippet).Updates(Snippet{
-		Title:       title,
-		Description: description,
-		Code:        code,
-	}).Error
-	if err != nil {
-		return err
+	if code != "" {
+		snippet.Code = code
 	}
-	return nil
 }
@@ -1,11 +1,16 @@
 package models
 
 import (
-	"github.com/google/uuid"
-	"github.com/topboyasante/go-snip/internal/database"
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	BaseModel
 	Username  string `json:"username" gorm:"unique"`
@@ -13,62 +18,74 @@ type User struct {
 	Password  string `json:"password"`
 	IsActive  bool   `json:"is_active"`
 	AuthToken int    `json:"auth_token"` //use this token for restting passwords, and verifying accounts
-}
+	Role      string `json:"role" gorm:"default:user"`
 
-func (user *User) Create() (*User, error) {
-	err := database.DB.Create(&user).Error
-	if err != nil {
-		return &User{}, err
-	}
-	return user, nil
+	// Public profile fields
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	Website     string `json:"website"`
+
+	// PendingEmail holds a new address until the user confirms it with PendingEmailToken. The token is kept apart
+	// from AuthToken, which signing in and resetting the password replace.
+	PendingEmail      string `json:"pending_email"`
+	PendingEmailToken int    `json:"pending_email_token"`
+	// Locale is the language tag the user prefers, such as "fr-CA", and picks the language of their emails
+	Locale string `json:"locale"`
+	// DeletionScheduledAt is set when the user asks to delete their account, and cleared if they cancel
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+	// TokenVersion is written into the tokens issued to the user, which are only accepted while it is unchanged.
+	// Changing the password or email bumps it, which revokes every token issued before.
+	TokenVersion int `json:"token_version"`
 }
 
 func (user *User) VerifyPassword(pw string) error {
 	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(pw))
 }
 
-func GetUserByEmail(email string) (User, error) {
-	var user User
-	err := database.DB.Where("email", email).Find(&user).Error
+func HashPassword(pw string) ([]byte, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
 	if err != nil {
-		return User{}, err
+		return nil, err
 	}
-	return user, nil
+	return hash, nil
 }
 
-func GetUserByUsername(username string) (User, error) {
-	var user User
-	err := database.DB.Where("username", username).Find(&user).Error
+// SetPassword hashes the password and stores the hash on the user, revoking their tokens.
+func (user *User) SetPassword(pw string) error {
+	hash, err := HashPassword(pw)
 	if err != nil {
-		return User{}, err
+		return err
 	}
-	return user, nil
+	user.Password = string(hash)
+	user.TokenVersion++
+	return nil
 }
 
-func GetUserById(id uuid.UUID) (User, error) {
-	var user User
-	err := database.DB.Where("ID=?", id).Find(&user).Error
-	if err != nil {
-		return User{}, err
-	}
-	return user, nil
+// UpdateProfile overwrites the public profile fields of the user, so empty values clear a field.
+func (user *User) UpdateProfile(displayName, bio, avatarURL, website string) {
+	user.DisplayName, user.Bio, user.AvatarURL, user.Website = displayName, bio, avatarURL, website
 }
 
-func HashPassword(pw string) ([]byte, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
-	}
-	return hash, nil
+// RequestEmailChange stores the new address as pending, along with the token needed to confirm it.
+func (user *User) RequestEmailChange(newEmail string, token int) {
+	user.PendingEmail = newEmail
+	user.PendingEmailToken = token
 }
 
-func (user *User) UpdatePassword(input string) (*User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(input), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
-	}
-	if err := database.DB.Model(&user).Update("password", hash); err != nil {
-		return &User{}, nil
-	}
-	return user, nil
+// ConfirmEmailChange replaces the user's email with their pending email, revoking their tokens.
+func (user *User) ConfirmEmailChange() {
+	user.Email = user.PendingEmail
+	user.PendingEmail = ""
+	user.PendingEmailToken = 0
+	user.TokenVersion++
+}
+
+// ScheduleDeletion marks the account for deletion once the grace period has passed.
+func (user *User) ScheduleDeletion(at time.Time) {
+	user.DeletionScheduledAt = &at
+}
+
+func (user *User) CancelDeletion() {
+	user.DeletionScheduledAt = nil
 }
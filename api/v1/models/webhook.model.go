@@ -0,0 +1,202 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/internal/database"
+	"gorm.io/gorm"
+)
+
+// Webhook subscribes a URL to events on the snippets of a user. Deliveries are sent by webhook.Deliveries.
+type Webhook struct {
+	ID        uuid.UUID `json:"id" gorm:"primarykey;type:uuid"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	URL       string    `json:"url" gorm:"column:url"`
+	// Secret is the key deliveries are signed with
+	Secret string   `json:"-"`
+	Events []string `json:"events" gorm:"serializer:json;not null"`
+	Active bool     `json:"active"`
+	// ConsecutiveFailures counts the failed attempts since the last successful one
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// DisabledAt is set when the webhook is disabled for failing too often
+	DisabledAt *time.Time `json:"disabled_at"`
+}
+
+// WebhookDelivery is an event waiting to be sent to a webhook, or the record of one that was sent or given up on.
+type WebhookDelivery struct {
+	ID        uuid.UUID `json:"id" gorm:"primarykey;type:uuid"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	WebhookID uuid.UUID `json:"webhook_id" gorm:"type:uuid"`
+	Event     string    `json:"event"`
+	// Payload is the exact body that is sent, and signed
+	Payload string `json:"payload"`
+	// RedeliveryOf is the delivery this one sends again, if it was redelivered on demand
+	RedeliveryOf *uuid.UUID `json:"redelivery_of" gorm:"type:uuid"`
+	// Attempts counts the failed attempts to send the delivery
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// StatusCode and DurationMS describe the last attempt. StatusCode is 0 if there was no response.
+	StatusCode  int        `json:"status_code"`
+	DurationMS  int64      `json:"duration_ms" gorm:"column:duration_ms"`
+	LastError   string     `json:"last_error"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	// FailedAt is set when the delivery is given up on
+	FailedAt *time.Time `json:"failed_at"`
+}
+
+func (webhook *Webhook) Create() error {
+	return database.DB.Create(webhook).Error
+}
+
+func (webhook *Webhook) Save() error {
+	return database.DB.Save(webhook).Error
+}
+
+// Delete removes a webhook along with its deliveries.
+func (webhook *Webhook) Delete() error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhook.ID).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(webhook).Error
+	})
+}
+
+// DeleteUserWebhooks removes every webhook of the given users, along with their deliveries.
+func DeleteUserWebhooks(userIDs ...uuid.UUID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		if err := tx.Model(&Webhook{}).Where("user_id IN ?", userIDs).Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := tx.Where("webhook_id IN ?", ids).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&Webhook{}).Error
+	})
+}
+
+// GetUserWebhook returns a webhook of a user, and whether it exists.
+func GetUserWebhook(userID, id uuid.UUID) (Webhook, bool, error) {
+	var webhook Webhook
+	result := database.DB.Where("id = ? AND user_id = ?", id, userID).Limit(1).Find(&webhook)
+	if result.Error != nil {
+		return Webhook{}, false, result.Error
+	}
+	return webhook, result.RowsAffected == 1, nil
+}
+
+func GetWebhook(id uuid.UUID) (Webhook, bool, error) {
+	var webhook Webhook
+	result := database.DB.Where("id = ?", id).Limit(1).Find(&webhook)
+	if result.Error != nil {
+		return Webhook{}, false, result.Error
+	}
+	return webhook, result.RowsAffected == 1, nil
+}
+
+// ListUserWebhooks returns the webhooks of a user, oldest first.
+func ListUserWebhooks(userID uuid.UUID) ([]Webhook, error) {
+	webhooks := []Webhook{}
+	err := database.DB.Where("user_id = ?", userID).Order("created_at").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ListActiveWebhooks returns the active webhooks of a user. Whether they subscribe to an event is left to the
+// caller, as events are stored as JSON.
+func ListActiveWebhooks(userID uuid.UUID) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := database.DB.Where("user_id = ? AND active = ?", userID, true).Find(&webhooks).Error
+	return webhooks, err
+}
+
+// RecordWebhookResult counts a successful or failed attempt to deliver to a webhook, and disables it once
+// disableAfter attempts in a row have failed. It reports whether the webhook was disabled by this failure.
+func RecordWebhookResult(id uuid.UUID, ok bool, disableAfter int, now time.Time) (bool, error) {
+	if ok {
+		return false, database.DB.Model(&Webhook{}).Where("id = ?", id).Update("consecutive_failures", 0).Error
+	}
+
+	err := database.DB.Model(&Webhook{}).Where("id = ?", id).
+		Update("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error
+	if err != nil {
+		return false, err
+	}
+
+	result := database.DB.Model(&Webhook{}).
+		Where("id = ? AND active = ? AND consecutive_failures >= ?", id, true, disableAfter).
+		Updates(map[string]any{"active": false, "disabled_at": now})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (delivery *WebhookDelivery) Create() error {
+	return database.DB.Create(delivery).Error
+}
+
+// Save writes every field of the delivery. Unlike gorm's Save it never inserts, so that a delivery whose webhook
+// was deleted while it was being sent stays deleted.
+func (delivery *WebhookDelivery) Save() error {
+	return database.DB.Model(delivery).Select("*").Updates(delivery).Error
+}
+
+// GetWebhookDelivery returns a delivery to a webhook, and whether it exists.
+func GetWebhookDelivery(webhookID, id uuid.UUID) (WebhookDelivery, bool, error) {
+	var delivery WebhookDelivery
+	result := database.DB.Where("id = ? AND webhook_id = ?", id, webhookID).Limit(1).Find(&delivery)
+	if result.Error != nil {
+		return WebhookDelivery{}, false, result.Error
+	}
+	return delivery, result.RowsAffected == 1, nil
+}
+
+// ListWebhookDeliveries returns one page of the deliveries to a webhook, newest first, along with the total count.
+func ListWebhookDeliveries(webhookID uuid.UUID, limit, offset int) ([]WebhookDelivery, int64, error) {
+	deliveries := []WebhookDelivery{}
+	var total int64
+
+	query := database.DB.Model(&WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	if err := query.Count(&total).Error; err != nil {
+		return []WebhookDelivery{}, 0, err
+	}
+
+	err := query.Order("created_at desc").Limit(limit).Offset(offset).Find(&deliveries).Error
+	if err != nil {
+		return []WebhookDelivery{}, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// ClaimDueWebhookDeliveries is ClaimDueOutboxMessages for webhook deliveries.
+func ClaimDueWebhookDeliveries(now time.Time, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	var due []WebhookDelivery
+	err := database.DB.
+		Where("delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&due).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := due[:0]
+	for _, delivery := range due {
+		result := database.DB.Model(&WebhookDelivery{}).
+			Where("id = ? AND delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", delivery.ID, now).
+			Update("next_attempt_at", now.Add(lease))
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			delivery.NextAttemptAt = now.Add(lease)
+			claimed = append(claimed, delivery)
+		}
+	}
+	return claimed, nil
+}
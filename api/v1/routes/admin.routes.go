@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/topboyasante/go-snip/api/v1/controllers"
+	"github.com/topboyasante/go-snip/api/v1/middleware"
+)
+
+func AdminRoutes(r *gin.RouterGroup) {
+	adminRoutes := r.Group("/admin")
+	adminRoutes.Use(middleware.RequireAuth, middleware.RequireAdmin)
+
+	adminRoutes.GET("/audit-log", controllers.GetAuditLog)
+}
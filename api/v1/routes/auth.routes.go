@@ -3,13 +3,19 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/topboyasante/go-snip/api/v1/controllers"
+	"github.com/topboyasante/go-snip/api/v1/middleware"
+	"github.com/topboyasante/go-snip/pkg/config"
 )
 
 func AuthRoutes(r *gin.RouterGroup) {
 	authRoutes := r.Group("/auth")
+	// Anonymous and sending email, so counted by IP address
+	authRoutes.Use(middleware.RateLimit("auth", config.ENV.RateLimitAuth, middleware.ByIP))
+
 	authRoutes.POST("/sign-in/", controllers.SignIn)
 	authRoutes.POST("/sign-up/", controllers.SignUp)
 	authRoutes.POST("/activate-account", controllers.ActivateAccount)
 	authRoutes.POST("/forgot-password", controllers.ForgotPassword)
 	authRoutes.POST("/reset-password", controllers.ResetPassword)
+	authRoutes.POST("/refresh-token", controllers.RefreshAccessToken)
 }
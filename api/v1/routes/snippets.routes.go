@@ -4,17 +4,25 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/topboyasante/go-snip/api/v1/controllers"
 	"github.com/topboyasante/go-snip/api/v1/middleware"
+	"github.com/topboyasante/go-snip/pkg/config"
 )
 
 func SnippetRoutes(r *gin.RouterGroup) {
 	snippetRoutes := r.Group("/snippets")
-	
+	snippetRoutes.Use(middleware.RateLimit("snippets", config.ENV.RateLimitSnippets, middleware.ByIP))
+
 	snippetRoutes.GET("", controllers.GetSnippets)
 	snippetRoutes.GET("/:id", controllers.GetSnippet)
-	
+	snippetRoutes.POST("/:id/render", controllers.RenderSnippet)
+
 	snippetRoutes.Use(middleware.RequireAuth)
-	
+	snippetRoutes.Use(middleware.RateLimit("snippet-writes", config.ENV.RateLimitSnippetWrites, middleware.ByUser))
+
 	snippetRoutes.POST("/create", controllers.CreateSnippet)
+	snippetRoutes.POST("/bulk", controllers.BulkSnippets)
+	snippetRoutes.POST("/import", controllers.ImportSnippets)
 	snippetRoutes.PUT("/:id", controllers.UpdateSnippet)
+	snippetRoutes.PATCH("/:id", controllers.PatchSnippet)
 	snippetRoutes.DELETE("/:id", controllers.DeleteSnippet)
+	snippetRoutes.GET("/:id/collaborate", controllers.CollaborateOnSnippet)
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/topboyasante/go-snip/api/v1/controllers"
+	"github.com/topboyasante/go-snip/api/v1/middleware"
+)
+
+func StreamRoutes(r *gin.RouterGroup) {
+	streamRoutes := r.Group("/stream")
+	streamRoutes.Use(middleware.OptionalAuth)
+
+	streamRoutes.GET("", controllers.StreamEvents)
+	streamRoutes.GET("/ws", controllers.StreamEventsWebSocket)
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/topboyasante/go-snip/api/v1/controllers"
+	"github.com/topboyasante/go-snip/api/v1/middleware"
+)
+
+func UserRoutes(r *gin.RouterGroup) {
+	userRoutes := r.Group("/users")
+	userRoutes.GET("/:username", controllers.GetUserProfile)
+	userRoutes.GET("/:username/snippets", controllers.GetUserSnippets)
+	userRoutes.GET("/:username/snippets/export", controllers.ExportUserSnippets)
+
+	meRoutes := r.Group("/me")
+	meRoutes.Use(middleware.RequireAuth)
+	meRoutes.GET("", controllers.GetMe)
+	meRoutes.PATCH("", controllers.UpdateMe)
+	meRoutes.DELETE("", controllers.DeleteAccount)
+	meRoutes.POST("/cancel-deletion", controllers.CancelAccountDeletion)
+	meRoutes.POST("/change-password", controllers.ChangePassword)
+	meRoutes.POST("/change-email", controllers.ChangeEmail)
+	meRoutes.POST("/confirm-email", controllers.ConfirmEmailChange)
+	meRoutes.GET("/export", controllers.ExportAccount)
+	meRoutes.GET("/audit-log", controllers.GetMyAuditLog)
+	meRoutes.GET("/usage", controllers.GetUsage)
+
+	webhookRoutes := meRoutes.Group("/webhooks")
+	webhookRoutes.GET("", controllers.ListWebhooks)
+	webhookRoutes.POST("", controllers.CreateWebhook)
+	webhookRoutes.GET("/:id", controllers.GetWebhook)
+	webhookRoutes.PATCH("/:id", controllers.UpdateWebhook)
+	webhookRoutes.DELETE("/:id", controllers.DeleteWebhook)
+	webhookRoutes.GET("/:id/deliveries", controllers.ListWebhookDeliveries)
+	webhookRoutes.POST("/:id/deliveries/:delivery_id/redeliver", controllers.RedeliverWebhookDelivery)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/topboyasante/go-snip/internal/database"
+	"github.com/topboyasante/go-snip/internal/migrate"
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/email"
+)
+
+// commit and buildTime are set when building, with
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Builds without them fall back to the version control information that Go records, if any.
+var (
+	commit    string
+	buildTime string
+)
+
+// readinessTimeout bounds the checks of /readyz, so that a hung database fails the probe rather than stalling it.
+const readinessTimeout = 2 * time.Second
+
+// healthz tells whether the process is alive, which it is if it can answer at all.
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, types.CheckResult{Status: types.StatusOK})
+}
+
+// readyz tells whether the process can serve requests: the database answers, is at the schema version of this
+// build, and the email outbox is being delivered. It answers 503 otherwise.
+func readyz(migrator *migrate.Migrator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		res := types.ReadinessResponse{
+			Database:   checkDatabase(ctx),
+			Migrations: checkMigrations(ctx, migrator),
+			Mail:       checkMailWorker(),
+		}
+
+		res.Status = types.StatusOK
+		status := http.StatusOK
+		for _, check := range []types.CheckResult{res.Database, res.Migrations.CheckResult, res.Mail.CheckResult} {
+			if check.Status != types.StatusOK {
+				res.Status, status = types.StatusUnavailable, http.StatusServiceUnavailable
+			}
+		}
+		c.JSON(status, res)
+	}
+}
+
+// Errors of the checks are logged rather than returned, since they may describe the network or the database.
+func checkDatabase(ctx context.Context) types.CheckResult {
+	db, err := database.DB.DB()
+	if err == nil {
+		err = db.PingContext(ctx)
+	}
+	if err != nil {
+		log.Println("readiness: database ping failed:", err)
+		return types.CheckResult{Status: types.StatusUnavailable, Error: "could not reach the database"}
+	}
+	return types.CheckResult{Status: types.StatusOK}
+}
+
+func checkMigrations(ctx context.Context, migrator *migrate.Migrator) types.MigrationCheck {
+	check := types.MigrationCheck{CheckResult: types.CheckResult{Status: types.StatusOK}, Expected: migrator.Latest()}
+
+	current, err := migrator.WithContext(ctx).Version()
+	if err != nil {
+		log.Println("readiness: reading the schema version failed:", err)
+		check.CheckResult = types.CheckResult{Status: types.StatusUnavailable, Error: "could not read the schema version"}
+		return check
+	}
+
+	check.Version = current
+	if current != check.Expected {
+		check.CheckResult = types.CheckResult{Status: types.StatusUnavailable, Error: "the database schema is not at the expected version"}
+	}
+	return check
+}
+
+func checkMailWorker() types.MailWorkerCheck {
+	status := email.Outbox.Status()
+	check := types.MailWorkerCheck{CheckResult: types.CheckResult{Status: types.StatusOK}, Running: status.Running}
+	if !status.LastRun.IsZero() {
+		check.LastRun = &status.LastRun
+	}
+
+	switch {
+	case !status.Running:
+		check.CheckResult = types.CheckResult{Status: types.StatusUnavailable, Error: "the outbox worker is not running"}
+	case status.LastError != "":
+		check.CheckResult = types.CheckResult{Status: types.StatusUnavailable, Error: "the outbox worker failed to check the outbox"}
+	}
+	return check
+}
+
+// version reports what this binary was built from.
+func version(c *gin.Context) {
+	c.JSON(http.StatusOK, buildInfo())
+}
+
+func buildInfo() types.VersionResponse {
+	info := types.VersionResponse{Commit: commit, BuildTime: buildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				// The time of the commit, which is the best there is without the build time
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/topboyasante/go-snip/api/v1/controllers"
+	"github.com/topboyasante/go-snip/api/v1/middleware"
 	"github.com/topboyasante/go-snip/api/v1/models"
 	"github.com/topboyasante/go-snip/api/v1/routes"
 	"github.com/topboyasante/go-snip/internal/database"
+	"github.com/topboyasante/go-snip/internal/migrate"
+	"github.com/topboyasante/go-snip/internal/store"
 	"github.com/topboyasante/go-snip/pkg/config"
+	"github.com/topboyasante/go-snip/pkg/email"
+	"github.com/topboyasante/go-snip/pkg/validators"
+	"github.com/topboyasante/go-snip/pkg/webhook"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -43,22 +53,93 @@ func init() {
 }
 
 func main() {
-	//Run AutoMigrations
-	err := database.DB.AutoMigrate(&models.User{}, &models.Snippet{})
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	migrator, err := migrate.New(database.DB, config.ENV.DBDriver)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The memory driver starts from an empty database every time, so it is always migrated.
+	// Everywhere else, migrations are an explicit step and a mismatched schema is fatal.
+	if config.ENV.DBDriver == "memory" {
+		if _, err := migrator.Up(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := migrator.Check(); err != nil {
+		log.Fatalf("%v; run \"go-snip migrate up\" first", err)
+	}
+
+	// Users and snippets go through the store selected by DB_DRIVER
+	s, err := store.New(config.ENV.DBDriver, database.DB)
 	if err != nil {
 		log.Fatal(err)
 	}
+	controllers.SetStore(s)
+	middleware.SetStore(s)
+
+	if err := validators.RegisterBindingRules(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Permanently delete accounts whose deletion grace period is over, and old emails
+	go purgeScheduledDeletions(s, time.Hour)
+
+	// Deliver the emails queued by the controllers
+	go email.Outbox.Run(context.Background())
+
+	// Deliver the snippet events queued for webhooks
+	go webhook.Deliveries.Run(context.Background())
 
-	r := gin.Default()
+	r := gin.New()
+	// Client IPs key rate limits, lockouts and the audit log, so they are only taken from trusted proxies
+	if err := r.SetTrustedProxies(config.ENV.TrustedProxies); err != nil {
+		log.Fatal("invalid TRUSTED_PROXIES: ", err)
+	}
+	r.Use(gin.Logger(), middleware.RequestID, gin.CustomRecovery(middleware.Recovery))
 	r.Use(cors.Default())
+	r.Use(middleware.ErrorHandler)
+	r.Use(middleware.LimitRequestBody(int64(config.ENV.MaxRequestBytes)))
+	r.NoRoute(middleware.NoRoute)
+
+	r.GET("/healthz", healthz)
+	r.GET("/readyz", readyz(migrator))
+	r.GET("/version", version)
 
 	v1 := r.Group("/api/v1")
 	{
 		routes.AuthRoutes(v1)
 		routes.SnippetRoutes(v1)
+		routes.UserRoutes(v1)
+		routes.AdminRoutes(v1)
+		routes.StreamRoutes(v1)
 	}
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	r.Run(config.ENV.ServerPort)
 }
+
+func purgeScheduledDeletions(s *store.Store, interval time.Duration) {
+	for ; ; time.Sleep(interval) {
+		now := time.Now()
+		purged, err := s.PurgeScheduledDeletions(now)
+		if err != nil {
+			log.Println("failed to purge deleted accounts:", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted accounts", purged)
+		}
+
+		// Sent and failed emails hold activation and reset codes in plain text
+		deleted, err := models.DeleteFinishedOutboxMessages(now.Add(-config.ENV.MailRetention))
+		if err != nil {
+			log.Println("failed to purge the email outbox:", err)
+		} else if deleted > 0 {
+			log.Printf("purged %d emails from the outbox", deleted)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/topboyasante/go-snip/internal/database"
+	"github.com/topboyasante/go-snip/internal/migrate"
+	"github.com/topboyasante/go-snip/pkg/config"
+)
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(args []string) {
+	migrator, err := migrate.New(database.DB, config.ENV.DBDriver)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = migrate.Run(migrator, args, os.Stdout)
+	if errors.Is(err, migrate.ErrUsage) {
+		fmt.Fprintf(os.Stderr, "%v\n\nusage: go-snip migrate <command>\n\n%s\n", err, migrate.Usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/topboyasante/go-snip/pkg/client"
+)
+
+func runLogin(ctx context.Context, server string, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	username := fs.String("username", "", "username, prompted for if empty")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	server = resolveServer(server, creds)
+
+	stdin := bufio.NewReader(os.Stdin)
+	if *username == "" {
+		if *username, err = prompt(stdin, "Username: ", false); err != nil {
+			return err
+		}
+	}
+	password, err := prompt(stdin, "Password: ", true)
+	if err != nil {
+		return err
+	}
+
+	c := client.New(server)
+	user, err := c.SignIn(ctx, *username, password)
+	if err != nil {
+		return err
+	}
+
+	err = saveCredentials(credentials{
+		Server:       server,
+		Username:     user.Username,
+		AccessToken:  user.AccessToken,
+		RefreshToken: user.RefeshToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "logged in to %s as %s\n", server, user.Username)
+	return nil
+}
+
+func runLogout() error {
+	return removeCredentials()
+}
+
+func runPush(ctx context.Context, server string, args []string) error {
+	fs := flag.NewFlagSet("push", flag.ContinueOnError)
+	title := fs.String("title", "", "title, defaults to the file name")
+	description := fs.String("description", "", "description")
+	var tags stringList
+	fs.Var(&tags, "tag", "tag, can be repeated")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		return fmt.Errorf("%w: expected one file", errUsage)
+	}
+
+	code, err := os.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+	if *title == "" {
+		*title = filepath.Base(files[0])
+	}
+
+	c, _, err := authenticatedClient(server)
+	if err != nil {
+		return err
+	}
+
+	snippet, err := c.CreateSnippet(ctx, client.NewSnippetRequest{
+		Title:       *title,
+		Description: *description,
+		Code:        string(code),
+		Tags:        tags,
+	})
+	if err != nil {
+		return explain(err)
+	}
+
+	fmt.Println(snippet.ID)
+	return nil
+}
+
+// runGet writes the code of a snippet to stdout as-is, so that it can be redirected to a file.
+func runGet(ctx context.Context, server string, args []string) error {
+	id, err := snippetID(args)
+	if err != nil {
+		return err
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+
+	snippet, err := client.New(resolveServer(server, creds)).GetSnippet(ctx, id)
+	if err != nil {
+		return explain(err)
+	}
+
+	_, err = os.Stdout.WriteString(snippet.Code)
+	return err
+}
+
+// runRender prints the code of a snippet with its variables filled in from --set name=value flags.
+func runRender(ctx context.Context, server string, args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	values := assignments{}
+	fs.Var(values, "set", "value of a variable, as name=value")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := snippetID(positional)
+	if err != nil {
+		return err
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+
+	rendered, err := client.New(resolveServer(server, creds)).RenderSnippet(ctx, id, values)
+	if err != nil {
+		return explain(err)
+	}
+
+	_, err = os.Stdout.WriteString(rendered.Code)
+	return err
+}
+
+func runList(ctx context.Context, server string, args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	tag := fs.String("tag", "", "only list snippets with this tag")
+	username := fs.String("user", "", "only list the snippets of this user, \"me\" for your own")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	c := client.New(resolveServer(server, creds))
+
+	if *username == "me" {
+		if creds.Username == "" {
+			return errors.New("not logged in, run \"snip login\" first")
+		}
+		*username = creds.Username
+	}
+
+	var snippets []client.Snippet
+	if *username == "" {
+		snippets, err = c.ListSnippets(ctx, *tag)
+	} else {
+		snippets, err = listAllUserSnippets(ctx, c, *username, *tag)
+	}
+	if err != nil {
+		return explain(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tOWNER\tTAGS\tUPDATED")
+	for _, snippet := range snippets {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", snippet.ID, snippet.Title, snippet.CreatedBy,
+			strings.Join(snippet.Tags, ","), snippet.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func listAllUserSnippets(ctx context.Context, c *client.Client, username, tag string) ([]client.Snippet, error) {
+	var snippets []client.Snippet
+	for page := 1; ; page++ {
+		res, err := c.ListUserSnippets(ctx, username, tag, page, 100)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, res.Items...)
+		if len(res.Items) == 0 || int64(len(snippets)) >= res.Total {
+			return snippets, nil
+		}
+	}
+}
+
+// runEdit opens the code of a snippet in $EDITOR and uploads it when the editor exits, if it changed.
+func runEdit(ctx context.Context, server string, args []string) error {
+	id, err := snippetID(args)
+	if err != nil {
+		return err
+	}
+
+	c, _, err := authenticatedClient(server)
+	if err != nil {
+		return err
+	}
+
+	snippet, err := c.GetSnippet(ctx, id)
+	if err != nil {
+		return explain(err)
+	}
+
+	// Keep the extension of the title, so that the editor picks the right syntax highlighting
+	file, err := os.CreateTemp("", "snip-*"+filepath.Ext(snippet.Title))
+	if err != nil {
+		return err
+	}
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(file.Name())
+		}
+	}()
+
+	_, err = file.WriteString(snippet.Code)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := openEditor(file.Name()); err != nil {
+		return err
+	}
+
+	code, err := os.ReadFile(file.Name())
+	if err != nil {
+		return err
+	}
+	if string(code) == snippet.Code {
+		fmt.Fprintln(os.Stderr, "no changes")
+		return nil
+	}
+	if strings.TrimSpace(string(code)) == "" {
+		return errors.New("the snippet is empty, not saving it")
+	}
+
+	// Only save over the version that was edited, so that changes made in the meantime are not lost
+	_, err = c.PatchSnippet(ctx, id, snippet.Version, client.SnippetPatch{"code": string(code)})
+	if errors.Is(err, client.ErrPreconditionFailed) {
+		keep = true
+		return fmt.Errorf("the snippet was changed while you were editing it; your version is saved in %s", file.Name())
+	}
+	if err != nil {
+		return explain(err)
+	}
+	fmt.Fprintln(os.Stderr, "snippet updated")
+	return nil
+}
+
+func runRemove(ctx context.Context, server string, args []string) error {
+	id, err := snippetID(args)
+	if err != nil {
+		return err
+	}
+
+	c, _, err := authenticatedClient(server)
+	if err != nil {
+		return err
+	}
+
+	return explain(c.DeleteSnippet(ctx, id))
+}
+
+// openEditor runs $VISUAL or $EDITOR, which may include arguments such as "code --wait".
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", editor, err)
+	}
+	return nil
+}
+
+// prompt reads a line from stdin. Secret input is not echoed when stdin is a terminal.
+func prompt(stdin *bufio.Reader, label string, secret bool) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+
+	if secret && isTerminal(os.Stdin) {
+		if err := stty("-echo"); err == nil {
+			defer func() {
+				stty("echo")
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// explain adds a hint to errors the user can do something about. The client has already tried refreshing
+// the tokens when a request fails with a 401.
+func explain(err error) error {
+	if errors.Is(err, client.ErrUnauthorized) {
+		return fmt.Errorf("%w; your session has expired, run \"snip login\"", err)
+	}
+
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && len(apiErr.Details) > 0 {
+		details := make([]string, len(apiErr.Details))
+		for i, detail := range apiErr.Details {
+			details[i] = detail.Field + " " + detail.Message
+		}
+		return fmt.Errorf("%w: %s", err, strings.Join(details, "; "))
+	}
+	return err
+}
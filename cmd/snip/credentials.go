@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/topboyasante/go-snip/pkg/client"
+)
+
+// credentials are what "snip login" saves for the other commands.
+type credentials struct {
+	Server       string `json:"server"`
+	Username     string `json:"username"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func credentialsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-snip", "credentials.json"), nil
+}
+
+// loadCredentials returns empty credentials, not an error, when the user has never logged in.
+func loadCredentials() (credentials, error) {
+	var creds credentials
+
+	path, err := credentialsPath()
+	if err != nil {
+		return creds, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return creds, nil
+	}
+	if err != nil {
+		return creds, err
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return creds, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return creds, nil
+}
+
+// saveCredentials writes the tokens readable by the current user only.
+func saveCredentials(creds credentials) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func removeCredentials() error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// resolveServer picks the -server flag or SNIP_SERVER, then the server of the last login.
+func resolveServer(server string, creds credentials) string {
+	if server != "" {
+		return server
+	}
+	if creds.Server != "" {
+		return creds.Server
+	}
+	return defaultServer
+}
+
+// authenticatedClient returns a client using the saved tokens, which saves them again whenever it refreshes them.
+func authenticatedClient(server string) (*client.Client, credentials, error) {
+	creds, err := loadCredentials()
+	if err != nil {
+		return nil, creds, err
+	}
+	server = resolveServer(server, creds)
+	if creds.AccessToken == "" || creds.Server != server {
+		return nil, creds, fmt.Errorf("not logged in to %s, run \"snip login\" first", server)
+	}
+
+	c := client.New(server,
+		client.WithTokens(creds.AccessToken, creds.RefreshToken),
+		client.OnTokenRefresh(func(tokens client.TokenResponse) {
+			creds.AccessToken, creds.RefreshToken = tokens.AccessToken, tokens.RefreshToken
+			if err := saveCredentials(creds); err != nil {
+				log.Printf("could not save the refreshed tokens: %v", err)
+			}
+		}),
+	)
+	return c, creds, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/topboyasante/go-snip/pkg/client"
+)
+
+// runExport downloads snippets for an editor or text expander, writing them to stdout unless -o is given.
+func runExport(ctx context.Context, server string, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "", "vscode, sublime, ultisnips, espanso or jetbrains")
+	username := fs.String("user", "", "export the snippets of this user instead of your own")
+	tag := fs.String("tag", "", "only export snippets with this tag")
+	output := fs.String("o", "", "file to write, instead of stdout")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *format == "" {
+		return fmt.Errorf("%w: expected --format", errUsage)
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	c := client.New(resolveServer(server, creds))
+
+	if *username == "" {
+		if creds.Username == "" {
+			return errors.New("not logged in, run \"snip login\" first or pass --user")
+		}
+		*username = creds.Username
+	}
+
+	data, err := c.ExportSnippets(ctx, *username, *format, *tag)
+	if err != nil {
+		return explain(err)
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported to %s\n", *output)
+	return nil
+}
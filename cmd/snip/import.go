@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/topboyasante/go-snip/pkg/client"
+)
+
+func runImport(ctx context.Context, server string, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "gist, vscode, jetbrains or dir")
+	dryRun := flags.Bool("dry-run", false, "show what would be imported without saving anything")
+	onConflict := flags.String("on-conflict", client.OnConflictSkip, "skip, overwrite or create snippets whose title is taken")
+	paths, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if *format == "" || len(paths) == 0 {
+		return fmt.Errorf("%w: expected --format and at least one file or directory", errUsage)
+	}
+
+	var files []client.ImportFile
+	for _, path := range paths {
+		found, err := readImportPath(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files to import in %s", strings.Join(paths, ", "))
+	}
+
+	c, _, err := authenticatedClient(server)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.ImportSnippets(ctx, client.ImportOptions{
+		Format:     *format,
+		DryRun:     *dryRun,
+		OnConflict: *onConflict,
+	}, files)
+	if err != nil {
+		return explain(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tTITLE\tSOURCE\tNOTE")
+	for _, item := range res.Items {
+		note := item.Reason
+		for _, detail := range item.Details {
+			note += fmt.Sprintf("; %s %s", detail.Field, detail.Message)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.Action, item.Title, item.Source, note)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("%d created, %d updated, %d skipped, %d failed", res.Created, res.Updated, res.Skipped, res.Failed)
+	if res.DryRun {
+		summary = "dry run, nothing was saved: " + summary
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	return nil
+}
+
+// readImportPath reads a file, or every file in a directory, skipping hidden files and directories like .git.
+// Files in a directory are named by their path relative to it.
+func readImportPath(root string) ([]client.ImportFile, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(root)
+		if err != nil {
+			return nil, err
+		}
+		return []client.ImportFile{{Name: filepath.Base(root), Data: data}}, nil
+	}
+
+	var files []client.ImportFile
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, client.ImportFile{Name: filepath.ToSlash(name), Data: data})
+		return nil
+	})
+	return files, err
+}
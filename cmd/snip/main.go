@@ -0,0 +1,156 @@
+// Command snip pushes and pulls snippets to and from a go-snip server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const usage = `usage: snip [-server <url>] <command> [arguments]
+
+commands:
+  login [-username <name>]
+  logout
+  push <file> [--title <title>] [--description <text>] [--tag <tag>]...
+  get <id>
+  render <id> [--set <name>=<value>]...
+  ls [--tag <tag>] [--user <username>]
+  edit <id>
+  rm <id>
+  import --format <gist|vscode|jetbrains|dir> [--dry-run] [--on-conflict <skip|overwrite|create>] <path>...
+  export --format <vscode|sublime|ultisnips|espanso|jetbrains> [--user <username>] [--tag <tag>] [-o <file>]
+
+The server defaults to SNIP_SERVER, then to the server of the last login, then to
+http://localhost:4000. Credentials are stored in the user config directory.`
+
+const defaultServer = "http://localhost:4000"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("snip: ")
+
+	fs := flag.NewFlagSet("snip", flag.ContinueOnError)
+	fs.Usage = func() {}
+	server := fs.String("server", os.Getenv("SNIP_SERVER"), "go-snip server URL")
+	if err := fs.Parse(os.Args[1:]); err != nil || fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	command, args := fs.Arg(0), fs.Args()[1:]
+	var err error
+	switch command {
+	case "login":
+		err = runLogin(ctx, *server, args)
+	case "logout":
+		err = runLogout()
+	case "push":
+		err = runPush(ctx, *server, args)
+	case "get":
+		err = runGet(ctx, *server, args)
+	case "render":
+		err = runRender(ctx, *server, args)
+	case "ls":
+		err = runList(ctx, *server, args)
+	case "edit":
+		err = runEdit(ctx, *server, args)
+	case "rm":
+		err = runRemove(ctx, *server, args)
+	case "import":
+		err = runImport(ctx, *server, args)
+	case "export":
+		err = runExport(ctx, *server, args)
+	default:
+		err = errUsage
+	}
+	stop()
+	exitOnError(err)
+}
+
+var errUsage = errors.New("invalid command")
+
+func exitOnError(err error) {
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s\n", err, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseArgs parses flags that come before or after the positional arguments, so that both
+// "snip push --title t file.go" and "snip push file.go --title t" work.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.Usage = func() {}
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// snippetID parses the single ID argument of get, edit and rm.
+func snippetID(args []string) (uuid.UUID, error) {
+	if len(args) != 1 {
+		return uuid.Nil, fmt.Errorf("%w: expected a snippet ID", errUsage)
+	}
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid snippet ID %q", args[0])
+	}
+	return id, nil
+}
+
+// stringList is a flag that can be repeated, and also accepts comma-separated values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// assignments is a flag of name=value pairs that can be repeated.
+type assignments map[string]string
+
+func (a assignments) String() string {
+	pairs := make([]string, 0, len(a))
+	for name, value := range a {
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (a assignments) Set(pair string) error {
+	name, value, ok := strings.Cut(pair, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", pair)
+	}
+	a[name] = value
+	return nil
+}
@@ -0,0 +1,96 @@
+// Command snipctl runs operations tasks against the go-snip database, using the same
+// configuration (.env and environment variables) as the API server.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/topboyasante/go-snip/internal/database"
+	"github.com/topboyasante/go-snip/internal/migrate"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/pkg/config"
+)
+
+const usage = `usage: snipctl <command> [arguments]
+
+commands:
+  user create -username <name> -email <email> [-password <pw>] [-role <role>] [-active]
+  user activate <username>
+  user reset-password <username> [-password <pw>]
+  user set-role <username> <user|admin>
+  migrate <up|down [n]|status>
+  seed [-password <pw>]
+  purge-expired
+
+Passwords that are not given are generated and printed.`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("snipctl: ")
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Everything snipctl does is lost when the process exits with the memory driver
+	if config.ENV.DBDriver == "memory" {
+		log.Fatal("snipctl needs a persistent database, set DB_DRIVER to postgres or sqlite")
+	}
+
+	database.ConnectToDB()
+
+	migrator, err := migrate.New(database.DB, config.ENV.DBDriver)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	if command == "migrate" {
+		err := migrate.Run(migrator, args, os.Stdout)
+		exitOnError(err)
+		return
+	}
+
+	// Every other command works on the current schema, just like the server
+	if err := migrator.Check(); err != nil {
+		log.Fatalf("%v; run \"snipctl migrate up\" first", err)
+	}
+
+	s, err := store.New(config.ENV.DBDriver, database.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch command {
+	case "user":
+		err = runUser(s, args)
+	case "seed":
+		err = runSeed(s, args)
+	case "purge-expired":
+		var purged int64
+		purged, err = s.Snippets.DeleteExpired(time.Now())
+		if err == nil {
+			fmt.Printf("purged %d expired snippets\n", purged)
+		}
+	default:
+		err = errUsage
+	}
+	exitOnError(err)
+}
+
+var errUsage = errors.New("invalid command")
+
+func exitOnError(err error) {
+	if errors.Is(err, errUsage) || errors.Is(err, migrate.ErrUsage) {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s\n", err, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
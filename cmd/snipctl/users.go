@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/pkg/auth"
+	"github.com/topboyasante/go-snip/pkg/password"
+	"github.com/topboyasante/go-snip/pkg/validators"
+)
+
+func runUser(s *store.Store, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "create":
+		return createUser(s, args[1:])
+	case "activate":
+		return activateUser(s, args[1:])
+	case "reset-password":
+		return resetPassword(s, args[1:])
+	case "set-role":
+		return setRole(s, args[1:])
+	default:
+		return errUsage
+	}
+}
+
+func createUser(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	username := fs.String("username", "", "username")
+	email := fs.String("email", "", "email address")
+	pw := fs.String("password", "", "password, generated if empty")
+	role := fs.String("role", models.RoleUser, "role, user or admin")
+	active := fs.Bool("active", false, "activate the account straight away")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if !validators.NotBlank(*username) || !validators.Matches(*email, validators.EmailRX) {
+		return fmt.Errorf("%w: a username and a valid email are required", errUsage)
+	}
+	if err := checkRole(*role); err != nil {
+		return err
+	}
+	if !auth.IsUsernameUnique(s.Users, *username) || !auth.IsEmailUnique(s.Users, *email) {
+		return errors.New("a user with that username or email already exists")
+	}
+
+	user := models.User{
+		Username:  *username,
+		Email:     *email,
+		Role:      *role,
+		IsActive:  *active,
+		AuthToken: auth.GenerateAuthToken(),
+	}
+	user.ID = uuid.New()
+
+	generated, err := setPassword(&user, *pw)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Users.Create(&user); err != nil {
+		return err
+	}
+
+	fmt.Printf("created user %s (%s)\n", user.Username, user.ID)
+	if generated != "" {
+		fmt.Printf("password: %s\n", generated)
+	}
+	return nil
+}
+
+func activateUser(s *store.Store, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	user, err := s.Users.GetByUsername(args[0])
+	if err != nil {
+		return userError(args[0], err)
+	}
+
+	user.IsActive = true
+	user.AuthToken = auth.GenerateAuthToken()
+	if err := s.Users.Save(&user); err != nil {
+		return err
+	}
+
+	fmt.Printf("activated %s\n", user.Username)
+	return nil
+}
+
+func resetPassword(s *store.Store, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	fs := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	pw := fs.String("password", "", "new password, generated if empty")
+	if err := fs.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+
+	user, err := s.Users.GetByUsername(args[0])
+	if err != nil {
+		return userError(args[0], err)
+	}
+
+	generated, err := setPassword(&user, *pw)
+	if err != nil {
+		return err
+	}
+
+	// Invalidate any reset code that is still in someone's inbox
+	user.AuthToken = auth.GenerateAuthToken()
+	if err := s.Users.Save(&user); err != nil {
+		return err
+	}
+
+	fmt.Printf("reset the password of %s\n", user.Username)
+	if generated != "" {
+		fmt.Printf("password: %s\n", generated)
+	}
+	return nil
+}
+
+func setRole(s *store.Store, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	if err := checkRole(args[1]); err != nil {
+		return err
+	}
+
+	user, err := s.Users.GetByUsername(args[0])
+	if err != nil {
+		return userError(args[0], err)
+	}
+
+	user.Role = args[1]
+	if err := s.Users.Save(&user); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now %s\n", user.Username, user.Role)
+	return nil
+}
+
+// setPassword sets the given password, or a generated one if it is empty. It returns the generated password.
+func setPassword(user *models.User, pw string) (string, error) {
+	generated := ""
+	if pw == "" {
+		buf := make([]byte, 18)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		pw = base64.RawURLEncoding.EncodeToString(buf)
+		generated = pw
+	}
+
+	if problems := password.Default.Check(pw, user.Username, user.Email); len(problems) > 0 {
+		return "", errors.New(strings.Join(problems, "; "))
+	}
+
+	return generated, user.SetPassword(pw)
+}
+
+func checkRole(role string) error {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return fmt.Errorf("unknown role %q, expected %s or %s", role, models.RoleUser, models.RoleAdmin)
+	}
+	return nil
+}
+
+func userError(username string, err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no user named %q", username)
+	}
+	return err
+}
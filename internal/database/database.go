@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 
+	"github.com/glebarez/sqlite"
 	"github.com/topboyasante/go-snip/pkg/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -16,10 +17,8 @@ var (
 
 func ConnectToDB() {
 	var err error
-	dsn := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", config.ENV.DBUser, config.ENV.DBPassword, config.ENV.DBHost, config.ENV.DBPort, config.ENV.DBName)
 
-
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(dialector(), &gorm.Config{})
 	if err != nil {
 		log.Fatal("failed to connect to the DB", err)
 	}
@@ -27,3 +26,17 @@ func ConnectToDB() {
 	fmt.Println("CONNECTED TO DB")
 
 }
+
+// dialector picks the database for config.ENV.DBDriver. The memory driver keeps users and snippets
+// in the store package, and uses an in-memory SQLite database for everything else.
+func dialector() gorm.Dialector {
+	switch config.ENV.DBDriver {
+	case "sqlite":
+		return sqlite.Open(config.ENV.DBPath)
+	case "memory":
+		return sqlite.Open("file::memory:?cache=shared")
+	default:
+		dsn := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", config.ENV.DBUser, config.ENV.DBPassword, config.ENV.DBHost, config.ENV.DBPort, config.ENV.DBName)
+		return postgres.Open(dsn)
+	}
+}
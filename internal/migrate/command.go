@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+const Usage = `commands:
+  up          apply every pending migration
+  down [n]    roll back the last n migrations (default 1)
+  status      list migrations and when they were applied`
+
+// ErrUsage is returned by Run when the arguments are not a valid command.
+var ErrUsage = errors.New("invalid migrate command")
+
+// Run implements the "migrate" command shared by go-snip and snipctl, writing its output to w.
+func Run(m *Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "up":
+		ran, err := m.Up()
+		for _, migration := range ran {
+			fmt.Fprintf(w, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("%w: invalid number of migrations %q", ErrUsage, args[1])
+			}
+			steps = n
+		}
+
+		ran, err := m.Down(steps)
+		for _, migration := range ran {
+			fmt.Fprintf(w, "rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return tw.Flush()
+
+	default:
+		return ErrUsage
+	}
+}
@@ -0,0 +1,62 @@
+-- Baseline schema. IF NOT EXISTS lets databases created by the old AutoMigrate adopt it, and the ALTER TABLEs
+-- below add the columns their users table predates.
+CREATE TABLE IF NOT EXISTS users (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    username text UNIQUE,
+    email text UNIQUE,
+    password text,
+    is_active boolean,
+    auth_token bigint,
+    role text DEFAULT 'user',
+    display_name text,
+    bio text,
+    avatar_url text,
+    website text,
+    pending_email text,
+    deletion_scheduled_at timestamptz
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role text DEFAULT 'user';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS website text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at timestamptz;
+
+CREATE TABLE IF NOT EXISTS snippets (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    title text,
+    description text,
+    code text,
+    user_id uuid,
+    CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key text PRIMARY KEY,
+    failures bigint,
+    last_failure_at timestamptz,
+    locked_until timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    actor_id uuid,
+    action text,
+    target_type text,
+    target_id text,
+    ip text,
+    user_agent text
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_snippets_expires_at;
+
+ALTER TABLE snippets DROP COLUMN expires_at;
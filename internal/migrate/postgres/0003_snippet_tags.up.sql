@@ -0,0 +1,7 @@
+CREATE TABLE snippet_tags (
+    snippet_id uuid NOT NULL,
+    tag text NOT NULL,
+    PRIMARY KEY (snippet_id, tag)
+);
+
+CREATE INDEX idx_snippet_tags_tag ON snippet_tags (tag);
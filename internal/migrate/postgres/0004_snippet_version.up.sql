@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN version bigint NOT NULL DEFAULT 1;
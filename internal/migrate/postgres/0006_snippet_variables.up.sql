@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN variables text NOT NULL DEFAULT '';
@@ -0,0 +1,15 @@
+CREATE TABLE outbox_messages (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    recipients text NOT NULL,
+    subject text NOT NULL,
+    html text NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_error text NOT NULL DEFAULT '',
+    sent_at timestamptz,
+    failed_at timestamptz
+);
+
+CREATE INDEX idx_outbox_messages_next_attempt_at ON outbox_messages (next_attempt_at);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE outbox_messages DROP COLUMN text_body;
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN locale text NOT NULL DEFAULT '';
+ALTER TABLE outbox_messages ADD COLUMN text_body text NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+CREATE TABLE rate_limit_buckets (
+    key text PRIMARY KEY,
+    tokens double precision NOT NULL,
+    updated_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL,
+    version bigint NOT NULL DEFAULT 1
+);
+
+CREATE INDEX idx_rate_limit_buckets_expires_at ON rate_limit_buckets (expires_at);
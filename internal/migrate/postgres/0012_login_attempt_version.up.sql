@@ -0,0 +1 @@
+ALTER TABLE login_attempts ADD COLUMN version bigint NOT NULL DEFAULT 0;
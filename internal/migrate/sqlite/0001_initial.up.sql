@@ -0,0 +1,54 @@
+-- Baseline schema. IF NOT EXISTS lets databases created by the old AutoMigrate adopt it as-is: SQLite support
+-- came after every column below, so unlike Postgres there are no older users tables to add columns to.
+CREATE TABLE IF NOT EXISTS users (
+    id text PRIMARY KEY,
+    created_at datetime,
+    updated_at datetime,
+    username text UNIQUE,
+    email text UNIQUE,
+    password text,
+    is_active numeric,
+    auth_token integer,
+    role text DEFAULT 'user',
+    display_name text,
+    bio text,
+    avatar_url text,
+    website text,
+    pending_email text,
+    deletion_scheduled_at datetime
+);
+
+CREATE TABLE IF NOT EXISTS snippets (
+    id text PRIMARY KEY,
+    created_at datetime,
+    updated_at datetime,
+    title text,
+    description text,
+    code text,
+    user_id text,
+    CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key text PRIMARY KEY,
+    failures integer,
+    last_failure_at datetime,
+    locked_until datetime,
+    updated_at datetime
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id text PRIMARY KEY,
+    created_at datetime,
+    actor_id text,
+    action text,
+    target_type text,
+    target_id text,
+    ip text,
+    user_agent text
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_id);
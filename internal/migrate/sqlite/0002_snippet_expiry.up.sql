@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN expires_at datetime;
+
+CREATE INDEX idx_snippets_expires_at ON snippets (expires_at);
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN version integer NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN "trigger" text NOT NULL DEFAULT '';
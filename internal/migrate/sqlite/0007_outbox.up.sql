@@ -0,0 +1,15 @@
+CREATE TABLE outbox_messages (
+    id text PRIMARY KEY,
+    created_at datetime,
+    updated_at datetime,
+    recipients text NOT NULL,
+    subject text NOT NULL,
+    html text NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at datetime NOT NULL,
+    last_error text NOT NULL DEFAULT '',
+    sent_at datetime,
+    failed_at datetime
+);
+
+CREATE INDEX idx_outbox_messages_next_attempt_at ON outbox_messages (next_attempt_at);
@@ -0,0 +1,37 @@
+CREATE TABLE webhooks (
+    id text PRIMARY KEY,
+    created_at datetime,
+    updated_at datetime,
+    user_id text NOT NULL,
+    url text NOT NULL,
+    secret text NOT NULL,
+    events text NOT NULL,
+    active numeric NOT NULL DEFAULT 1,
+    consecutive_failures integer NOT NULL DEFAULT 0,
+    disabled_at datetime,
+    CONSTRAINT fk_webhooks_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhooks_user_id ON webhooks (user_id);
+
+CREATE TABLE webhook_deliveries (
+    id text PRIMARY KEY,
+    created_at datetime,
+    updated_at datetime,
+    webhook_id text NOT NULL,
+    event text NOT NULL,
+    payload text NOT NULL,
+    redelivery_of text,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at datetime NOT NULL,
+    status_code integer NOT NULL DEFAULT 0,
+    response_body text NOT NULL DEFAULT '',
+    duration_ms integer NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    delivered_at datetime,
+    failed_at datetime,
+    CONSTRAINT fk_webhook_deliveries_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, created_at);
+CREATE INDEX idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
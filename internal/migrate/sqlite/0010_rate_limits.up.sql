@@ -0,0 +1,9 @@
+CREATE TABLE rate_limit_buckets (
+    key text PRIMARY KEY,
+    tokens real NOT NULL,
+    updated_at datetime NOT NULL,
+    expires_at datetime NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX idx_rate_limit_buckets_expires_at ON rate_limit_buckets (expires_at);
@@ -0,0 +1 @@
+ALTER TABLE login_attempts ADD COLUMN version integer NOT NULL DEFAULT 0;
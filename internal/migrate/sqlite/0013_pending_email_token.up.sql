@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN pending_email_token integer NOT NULL DEFAULT 0;
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"gorm.io/gorm"
+)
+
+// NewGormStore returns a store backed by any database GORM can talk to. It is used for both Postgres and SQLite.
+func NewGormStore(db *gorm.DB) *Store {
+	return &Store{
+		Users:    &gormUserStore{db: db},
+		Snippets: &gormSnippetStore{db: db},
+		transaction: func(fn func(tx *Store) error) error {
+			// The transactions of the stores nest as savepoints
+			return db.Transaction(func(tx *gorm.DB) error {
+				return fn(NewGormStore(tx))
+			})
+		},
+	}
+}
+
+type gormUserStore struct {
+	db *gorm.DB
+}
+
+func (s *gormUserStore) Create(user *models.User) error {
+	return s.db.Create(user).Error
+}
+
+func (s *gormUserStore) Save(user *models.User) error {
+	return s.db.Save(user).Error
+}
+
+func (s *gormUserStore) GetByID(id uuid.UUID) (models.User, error) {
+	return s.first("id = ?", id)
+}
+
+func (s *gormUserStore) GetByEmail(email string) (models.User, error) {
+	return s.first("email = ?", email)
+}
+
+func (s *gormUserStore) GetByUsername(username string) (models.User, error) {
+	return s.first("username = ?", username)
+}
+
+// first returns ErrNotFound rather than using GORM's First, which logs every miss as an error.
+func (s *gormUserStore) first(query string, args ...any) (models.User, error) {
+	var user models.User
+	result := s.db.Where(query, args...).Limit(1).Find(&user)
+	if result.Error != nil {
+		return models.User{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *gormUserStore) ListScheduledForDeletion(before time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := s.db.Model(&models.User{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", before).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (s *gormUserStore) Delete(ids ...uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.Where("id IN ?", ids).Delete(&models.User{}).Error
+}
+
+type gormSnippetStore struct {
+	db *gorm.DB
+}
+
+func (s *gormSnippetStore) Create(snippet *models.Snippet) error {
+	snippet.Version = 1
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Omit the preloaded owner so that saving a snippet never writes to the users table
+		if err := tx.Omit("User").Create(snippet).Error; err != nil {
+			return err
+		}
+		return replaceTags(tx, snippet.ID, snippet.Tags)
+	})
+}
+
+func (s *gormSnippetStore) Save(snippet *models.Snippet) error {
+	version := snippet.Version
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		snippet.Version = version + 1
+		result := tx.Model(snippet).Where("version = ?", version).Select("*").Omit("User", "CreatedAt").Updates(snippet)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+		return replaceTags(tx, snippet.ID, snippet.Tags)
+	})
+	if err != nil {
+		snippet.Version = version
+	}
+	return err
+}
+
+func (s *gormSnippetStore) Get(id uuid.UUID) (models.Snippet, error) {
+	var snippets []models.Snippet
+	err := s.db.Scopes(notExpired).Preload("User").Where("id = ?", id).Limit(1).Find(&snippets).Error
+	if err != nil {
+		return models.Snippet{}, err
+	}
+	if len(snippets) == 0 {
+		return models.Snippet{}, ErrNotFound
+	}
+	if err := s.loadTags(snippets); err != nil {
+		return models.Snippet{}, err
+	}
+	return snippets[0], nil
+}
+
+func (s *gormSnippetStore) List(q SnippetQuery) ([]models.Snippet, int64, error) {
+	var snippets []models.Snippet
+	var total int64
+
+	query := s.db.Model(&models.Snippet{})
+	if !q.IncludeExpired {
+		query = query.Scopes(notExpired)
+	}
+	if q.UserID != uuid.Nil {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if q.Tag != "" {
+		query = query.Where("id IN (?)", s.db.Model(&models.SnippetTag{}).Select("snippet_id").Where("tag = ?", q.Tag))
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return []models.Snippet{}, 0, err
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = -1
+	}
+
+	err := query.Preload("User").Order("created_at desc").Limit(limit).Offset(q.Offset).Find(&snippets).Error
+	if err != nil {
+		return []models.Snippet{}, 0, err
+	}
+	if err := s.loadTags(snippets); err != nil {
+		return []models.Snippet{}, 0, err
+	}
+	return snippets, total, nil
+}
+
+func (s *gormSnippetStore) Delete(id uuid.UUID, version int64) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("id = ?", id)
+		if version != 0 {
+			query = query.Where("version = ?", version)
+		}
+		result := query.Delete(&models.Snippet{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if version != 0 && result.RowsAffected == 0 {
+			return ErrConflict
+		}
+		return tx.Where("snippet_id = ?", id).Delete(&models.SnippetTag{}).Error
+	})
+}
+
+func (s *gormSnippetStore) DeleteByUsers(userIDs ...uuid.UUID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return s.deleteWhere("user_id IN ?", userIDs)
+}
+
+func (s *gormSnippetStore) Usage(userID uuid.UUID) (SnippetUsage, error) {
+	// LENGTH counts characters, so bytes are counted by the length of the encoded code
+	bytes := "LENGTH(CAST(code AS BLOB))"
+	if s.db.Dialector.Name() == "postgres" {
+		bytes = "OCTET_LENGTH(code)"
+	}
+
+	var usage SnippetUsage
+	err := s.db.Model(&models.Snippet{}).
+		Select("COUNT(*) AS snippets, COALESCE(SUM("+bytes+"), 0) AS bytes").
+		Where("user_id = ?", userID).
+		Scan(&usage).Error
+	return usage, err
+}
+
+func (s *gormSnippetStore) DeleteExpired(before time.Time) (int64, error) {
+	var deleted int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&models.Snippet{}).Select("id").Where("expires_at IS NOT NULL AND expires_at <= ?", before)
+		if err := tx.Where("snippet_id IN (?)", expired).Delete(&models.SnippetTag{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("expires_at IS NOT NULL AND expires_at <= ?", before).Delete(&models.Snippet{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+// deleteWhere removes the matching snippets along with their tags.
+func (s *gormSnippetStore) deleteWhere(query string, args ...any) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		matching := tx.Model(&models.Snippet{}).Select("id").Where(query, args...)
+		if err := tx.Where("snippet_id IN (?)", matching).Delete(&models.SnippetTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Where(query, args...).Delete(&models.Snippet{}).Error
+	})
+}
+
+// loadTags fills in the tags of every snippet with a single query. They are sorted here rather than by the
+// database, whose collation may not order them the way the memory store does.
+func (s *gormSnippetStore) loadTags(snippets []models.Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(snippets))
+	for i, snippet := range snippets {
+		ids[i] = snippet.ID
+	}
+
+	var rows []models.SnippetTag
+	if err := s.db.Where("snippet_id IN ?", ids).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	tags := make(map[uuid.UUID][]string)
+	for _, row := range rows {
+		tags[row.SnippetID] = append(tags[row.SnippetID], row.Tag)
+	}
+	for i := range snippets {
+		snippets[i].Tags = tags[snippets[i].ID]
+		slices.Sort(snippets[i].Tags)
+	}
+	return nil
+}
+
+func replaceTags(tx *gorm.DB, snippetID uuid.UUID, tags []string) error {
+	if err := tx.Where("snippet_id = ?", snippetID).Delete(&models.SnippetTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	rows := make([]models.SnippetTag, len(tags))
+	for i, tag := range tags {
+		rows[i] = models.SnippetTag{SnippetID: snippetID, Tag: tag}
+	}
+	return tx.Create(&rows).Error
+}
+
+func notExpired(db *gorm.DB) *gorm.DB {
+	return db.Where("snippets.expires_at IS NULL OR snippets.expires_at > ?", time.Now())
+}
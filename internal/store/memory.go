@@ -0,0 +1,368 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+)
+
+// ErrDuplicate is returned by the memory store when a unique field is already taken, like a database constraint would.
+var ErrDuplicate = errors.New("duplicate key")
+
+// memoryDB holds every record of a memory store. It is shared by the user and snippet stores
+// so that snippets can be returned with their owner, the way GORM preloads them.
+type memoryDB struct {
+	mu       sync.RWMutex
+	users    map[uuid.UUID]models.User
+	snippets map[uuid.UUID]models.Snippet
+}
+
+// NewMemoryStore returns a store that keeps everything in process memory, for local development and tests.
+// Nothing survives a restart.
+func NewMemoryStore() *Store {
+	db := &memoryDB{
+		users:    make(map[uuid.UUID]models.User),
+		snippets: make(map[uuid.UUID]models.Snippet),
+	}
+	return db.store(nil)
+}
+
+// store returns a store of the records in db, which remembers how to undo its writes in undo if it is not nil.
+func (db *memoryDB) store(undo *undoLog) *Store {
+	s := &Store{
+		Users:    &memoryUserStore{db: db, undo: undo},
+		Snippets: &memorySnippetStore{db: db, undo: undo},
+	}
+	s.transaction = func(fn func(tx *Store) error) error {
+		return db.transaction(undo, fn)
+	}
+	return s
+}
+
+// transaction rolls back a failed fn by undoing the writes it made, and only those, so that other requests can
+// keep writing while it runs. A transaction nested in another one hands its writes to the outer one when it
+// succeeds, so that they are undone too if the outer one fails.
+func (db *memoryDB) transaction(outer *undoLog, fn func(tx *Store) error) error {
+	undo := &undoLog{}
+	err := fn(db.store(undo))
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err != nil {
+		undo.rollback()
+		return err
+	}
+	if outer != nil {
+		outer.steps = append(outer.steps, undo.steps...)
+	}
+	return nil
+}
+
+// undoLog records how to restore what the writes of a transaction replaced. It must only be used with the lock
+// held. Rolling back puts back the records the transaction wrote as they were before it, even if another request
+// wrote them since, as the lock is not held for the whole transaction.
+type undoLog struct {
+	steps []func()
+}
+
+// remember records how to restore the current value of key in m, or its absence. A nil log remembers nothing.
+func remember[K comparable, V any](undo *undoLog, m map[K]V, key K) {
+	if undo == nil {
+		return
+	}
+	value, ok := m[key]
+	undo.steps = append(undo.steps, func() {
+		if ok {
+			m[key] = value
+		} else {
+			delete(m, key)
+		}
+	})
+}
+
+// rollback undoes the remembered writes, latest first.
+func (undo *undoLog) rollback() {
+	for i := len(undo.steps) - 1; i >= 0; i-- {
+		undo.steps[i]()
+	}
+	undo.steps = nil
+}
+
+type memoryUserStore struct {
+	db   *memoryDB
+	undo *undoLog
+}
+
+func (s *memoryUserStore) Create(user *models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[user.ID]; ok {
+		return ErrDuplicate
+	}
+	if err := s.checkUnique(*user); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.CreatedAt, user.UpdatedAt = now, now
+	remember(s.undo, s.db.users, user.ID)
+	s.db.users[user.ID] = *user
+	return nil
+}
+
+func (s *memoryUserStore) Save(user *models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if err := s.checkUnique(*user); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	remember(s.undo, s.db.users, user.ID)
+	s.db.users[user.ID] = *user
+	return nil
+}
+
+// checkUnique must be called with the lock held.
+func (s *memoryUserStore) checkUnique(user models.User) error {
+	for id, existing := range s.db.users {
+		if id == user.ID {
+			continue
+		}
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	return nil
+}
+
+func (s *memoryUserStore) GetByID(id uuid.UUID) (models.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	user, ok := s.db.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *memoryUserStore) GetByEmail(email string) (models.User, error) {
+	return s.find(func(user models.User) bool { return user.Email == email })
+}
+
+func (s *memoryUserStore) GetByUsername(username string) (models.User, error) {
+	return s.find(func(user models.User) bool { return user.Username == username })
+}
+
+func (s *memoryUserStore) find(match func(models.User) bool) (models.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, user := range s.db.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (s *memoryUserStore) ListScheduledForDeletion(before time.Time) ([]uuid.UUID, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var ids []uuid.UUID
+	for id, user := range s.db.users {
+		if user.DeletionScheduledAt != nil && !user.DeletionScheduledAt.After(before) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *memoryUserStore) Delete(ids ...uuid.UUID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, id := range ids {
+		remember(s.undo, s.db.users, id)
+		delete(s.db.users, id)
+	}
+	return nil
+}
+
+type memorySnippetStore struct {
+	db   *memoryDB
+	undo *undoLog
+}
+
+func (s *memorySnippetStore) Create(snippet *models.Snippet) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.snippets[snippet.ID]; ok {
+		return ErrDuplicate
+	}
+
+	now := time.Now()
+	snippet.CreatedAt, snippet.UpdatedAt = now, now
+	snippet.Version = 1
+	remember(s.undo, s.db.snippets, snippet.ID)
+	s.db.snippets[snippet.ID] = cloneSnippet(*snippet)
+	return nil
+}
+
+func (s *memorySnippetStore) Save(snippet *models.Snippet) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored, ok := s.db.snippets[snippet.ID]
+	if !ok || stored.Version != snippet.Version {
+		return ErrConflict
+	}
+
+	snippet.CreatedAt = stored.CreatedAt
+	snippet.UpdatedAt = time.Now()
+	snippet.Version++
+	remember(s.undo, s.db.snippets, snippet.ID)
+	s.db.snippets[snippet.ID] = cloneSnippet(*snippet)
+	return nil
+}
+
+func (s *memorySnippetStore) Get(id uuid.UUID) (models.Snippet, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	snippet, ok := s.db.snippets[id]
+	if !ok || snippet.IsExpired(time.Now()) {
+		return models.Snippet{}, ErrNotFound
+	}
+	return s.withUser(snippet), nil
+}
+
+func (s *memorySnippetStore) List(q SnippetQuery) ([]models.Snippet, int64, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	now := time.Now()
+	var snippets []models.Snippet
+	for _, snippet := range s.db.snippets {
+		if snippet.IsExpired(now) && !q.IncludeExpired {
+			continue
+		}
+		if q.UserID != uuid.Nil && snippet.UserID != q.UserID {
+			continue
+		}
+		if q.Tag != "" && !slices.Contains(snippet.Tags, q.Tag) {
+			continue
+		}
+		snippets = append(snippets, s.withUser(snippet))
+	}
+
+	sort.Slice(snippets, func(i, j int) bool {
+		return snippets[i].CreatedAt.After(snippets[j].CreatedAt)
+	})
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = -1
+	}
+	return paginate(snippets, limit, q.Offset), int64(len(snippets)), nil
+}
+
+func (s *memorySnippetStore) Delete(id uuid.UUID, version int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if stored, ok := s.db.snippets[id]; version != 0 && (!ok || stored.Version != version) {
+		return ErrConflict
+	}
+	remember(s.undo, s.db.snippets, id)
+	delete(s.db.snippets, id)
+	return nil
+}
+
+func (s *memorySnippetStore) DeleteByUsers(userIDs ...uuid.UUID) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	owners := make(map[uuid.UUID]bool, len(userIDs))
+	for _, id := range userIDs {
+		owners[id] = true
+	}
+	for id, snippet := range s.db.snippets {
+		if owners[snippet.UserID] {
+			remember(s.undo, s.db.snippets, id)
+			delete(s.db.snippets, id)
+		}
+	}
+	return nil
+}
+
+func (s *memorySnippetStore) Usage(userID uuid.UUID) (SnippetUsage, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var usage SnippetUsage
+	for _, snippet := range s.db.snippets {
+		if snippet.UserID == userID {
+			usage.Snippets++
+			usage.Bytes += int64(len(snippet.Code))
+		}
+	}
+	return usage, nil
+}
+
+func (s *memorySnippetStore) DeleteExpired(before time.Time) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var deleted int64
+	for id, snippet := range s.db.snippets {
+		if snippet.IsExpired(before) {
+			remember(s.undo, s.db.snippets, id)
+			delete(s.db.snippets, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// withUser returns a copy of the snippet with its owner attached. It must be called with the lock held.
+func (s *memorySnippetStore) withUser(snippet models.Snippet) models.Snippet {
+	snippet = cloneSnippet(snippet)
+	snippet.User = s.db.users[snippet.UserID]
+	return snippet
+}
+
+// cloneSnippet copies the tags and variables, so that callers cannot modify a stored snippet through the shared
+// slices. The tags are sorted, as the GORM store loads them.
+func cloneSnippet(snippet models.Snippet) models.Snippet {
+	snippet.Tags = slices.Clone(snippet.Tags)
+	slices.Sort(snippet.Tags)
+	snippet.Variables = slices.Clone(snippet.Variables)
+	return snippet
+}
+
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when a lookup matches no record.
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned when a record was changed or removed since the version the caller read.
+var ErrConflict = errors.New("record was modified concurrently")
+
+type UserStore interface {
+	Create(user *models.User) error
+	// Save writes every field of the user, including zero values
+	Save(user *models.User) error
+	GetByID(id uuid.UUID) (models.User, error)
+	GetByEmail(email string) (models.User, error)
+	GetByUsername(username string) (models.User, error)
+	// ListScheduledForDeletion returns the IDs of users whose deletion grace period ended before the given time
+	ListScheduledForDeletion(before time.Time) ([]uuid.UUID, error)
+	Delete(ids ...uuid.UUID) error
+}
+
+// SnippetQuery filters and pages SnippetStore.List. Zero values are ignored, and a zero Limit returns every match.
+type SnippetQuery struct {
+	UserID uuid.UUID
+	Tag    string
+	Limit  int
+	Offset int
+	// IncludeExpired also returns the snippets that expired but are not purged yet
+	IncludeExpired bool
+}
+
+// SnippetUsage is how much a user stores. Expired snippets count until they are purged.
+type SnippetUsage struct {
+	Snippets int64
+	// Bytes is the total length of the code of the snippets, in bytes
+	Bytes int64
+}
+
+// SnippetStore never returns expired snippets from its getters, even before they are purged, unless a SnippetQuery
+// asks for them.
+type SnippetStore interface {
+	// Create and Save also store the snippet's tags
+	Create(snippet *models.Snippet) error
+	// Save writes every field of the snippet, including zero values, and bumps its version. It returns ErrConflict
+	// if the stored snippet is no longer at the version it was read at.
+	Save(snippet *models.Snippet) error
+	Get(id uuid.UUID) (models.Snippet, error)
+	// List returns one page of matching snippets, newest first, along with the total count
+	List(query SnippetQuery) ([]models.Snippet, int64, error)
+	// Delete removes a snippet. A non-zero version makes it return ErrConflict unless the snippet is still at that version.
+	Delete(id uuid.UUID, version int64) error
+	DeleteByUsers(userIDs ...uuid.UUID) error
+	// Usage returns how much a user stores
+	Usage(userID uuid.UUID) (SnippetUsage, error)
+	// DeleteExpired removes every snippet that expired before the given time, and returns how many it removed
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+// Store groups the repositories that controllers and middleware depend on.
+type Store struct {
+	Users    UserStore
+	Snippets SnippetStore
+
+	transaction func(fn func(tx *Store) error) error
+}
+
+// Transaction runs fn with a store whose writes are all undone if fn returns an error.
+func (s *Store) Transaction(fn func(tx *Store) error) error {
+	return s.transaction(fn)
+}
+
+// New returns the store for the configured driver. "postgres" and "sqlite" are backed by db,
+// while "memory" keeps everything in process memory and ignores db.
+func New(driver string, db *gorm.DB) (*Store, error) {
+	switch driver {
+	case "postgres", "sqlite":
+		return NewGormStore(db), nil
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown store driver %q", driver)
+	}
+}
+
+// PurgeScheduledDeletions permanently removes every account whose grace period is over, with its snippets and
+// webhooks. Nothing relies on ON DELETE CASCADE, which SQLite and the memory driver do not enforce.
+func (s *Store) PurgeScheduledDeletions(now time.Time) (int, error) {
+	ids, err := s.Users.ListScheduledForDeletion(now)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	// Webhooks are kept outside the store, so they are removed first, on their own. If the rest fails, the
+	// accounts are still due and the next purge removes them.
+	if err := models.DeleteUserWebhooks(ids...); err != nil {
+		return 0, err
+	}
+	err = s.Transaction(func(tx *Store) error {
+		if err := tx.Snippets.DeleteByUsers(ids...); err != nil {
+			return err
+		}
+		return tx.Users.Delete(ids...)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
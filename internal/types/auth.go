@@ -2,23 +2,43 @@ package types
 
 import "github.com/google/uuid"
 
+// The binding tags are checked by controllers when a request is bound; see validators.RegisterBindingRules
+// for the rules that are not built into the validator.
+
 type UserLoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" binding:"notblank"`
+	Password string `json:"password" binding:"required"`
 }
 
 type UserSignUpRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username string `json:"username" binding:"notblank,max=50"`
+	Email    string `json:"email" binding:"required,email,max=254"`
+	Password string `json:"password" binding:"required,max=128"`
+	// Locale is the language of the user's emails, and defaults to the first language in Accept-Language
+	Locale string `json:"locale,omitempty" binding:"omitempty,max=35,bcp47_language_tag"`
 }
 
 type ActivateAccountRequest struct {
-	Email     string `json:"email"`
-	AuthToken int    `json:"auth_token"`
+	Email     string `json:"email" binding:"required,email"`
+	AuthToken int    `json:"auth_token" binding:"required,min=1000,max=9999"`
 }
 type ForgotPasswordRequest struct {
-	Email     string `json:"email"`
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	AuthToken   int    `json:"auth_token" binding:"required,min=1000,max=9999"`
+	NewPassword string `json:"new_password" binding:"required,max=128"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type UserResponse struct {
@@ -0,0 +1,43 @@
+package types
+
+import "time"
+
+// Statuses of a readiness check
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// ReadinessResponse is the body of /readyz. Status is "ok" when every check is, and "unavailable" otherwise.
+type ReadinessResponse struct {
+	Status     string          `json:"status"`
+	Database   CheckResult     `json:"database"`
+	Migrations MigrationCheck  `json:"migrations"`
+	Mail       MailWorkerCheck `json:"mail"`
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// MigrationCheck fails unless the database is at the schema version this build expects.
+type MigrationCheck struct {
+	CheckResult
+	Version  int `json:"version"`
+	Expected int `json:"expected"`
+}
+
+// MailWorkerCheck fails if the email outbox worker stopped, or failed to check the outbox the last time it did.
+type MailWorkerCheck struct {
+	CheckResult
+	Running bool       `json:"running"`
+	LastRun *time.Time `json:"last_run,omitempty"`
+}
+
+// VersionResponse is the body of /version. Commit and BuildTime are set at build time, see cmd/health.go.
+type VersionResponse struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
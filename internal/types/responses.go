@@ -1,7 +1,13 @@
 package types
 
+import "github.com/topboyasante/go-snip/pkg/apierror"
+
+// APIErrorMessage is the body of every error response. Clients should branch on Code, not on the message.
 type APIErrorMessage struct {
-	ErrorMessage string `json:"error"`
+	ErrorMessage string                `json:"error"`
+	Code         apierror.Code         `json:"code"`
+	Details      []apierror.FieldError `json:"details,omitempty"`
+	RequestID    string                `json:"request_id,omitempty"`
 }
 
 type APISuccessMessage struct {
@@ -4,20 +4,204 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+	"github.com/topboyasante/go-snip/pkg/placeholder"
 )
 
+// A snippet has a title of up to 200 characters, a description of up to 2000, code of up to SNIPPET_MAX_BYTES
+// bytes, a trigger of up to 64 characters without spaces, up to 10 tags of up to 32 characters each and up to 20
+// variables.
 type NewSnippetRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Code        string `json:"code"`
+	Title       string            `json:"title" binding:"notblank,max=200"`
+	Description string            `json:"description" binding:"max=2000"`
+	Code        string            `json:"code" binding:"notblank"`
+	Trigger     string            `json:"trigger,omitempty" binding:"max=64,nospace"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty" binding:"omitempty,gt"`
+	Tags        []string          `json:"tags,omitempty" binding:"max=10,dive,max=32"`
+	Variables   []SnippetVariable `json:"variables,omitempty" binding:"max=20,dive"`
 }
+
+// UpdateSnippetRequest leaves empty fields unchanged. Tags and variables replace the current ones when present.
+type UpdateSnippetRequest struct {
+	Title       string             `json:"title,omitempty" binding:"max=200"`
+	Description string             `json:"description,omitempty" binding:"max=2000"`
+	Code        string             `json:"code,omitempty"`
+	Trigger     string             `json:"trigger,omitempty" binding:"max=64,nospace"`
+	Tags        *[]string          `json:"tags,omitempty" binding:"omitempty,max=10,dive,max=32"`
+	Variables   *[]SnippetVariable `json:"variables,omitempty" binding:"omitempty,max=20,dive"`
+}
+
+// PatchSnippetRequest is a JSON Merge Patch (RFC 7396) of a snippet: fields that are absent are left unchanged and
+// null clears description, trigger, expires_at, tags and variables. Title and code cannot be cleared.
+type PatchSnippetRequest struct {
+	Title       *string            `json:"title" binding:"omitempty,notblank,max=200"`
+	Description *string            `json:"description" binding:"omitempty,max=2000"`
+	Code        *string            `json:"code" binding:"omitempty,notblank"`
+	Trigger     *string            `json:"trigger" binding:"omitempty,max=64,nospace"`
+	ExpiresAt   *time.Time         `json:"expires_at" binding:"omitempty,gt"`
+	Tags        *[]string          `json:"tags" binding:"omitempty,max=10,dive,max=32"`
+	Variables   *[]SnippetVariable `json:"variables" binding:"omitempty,max=20,dive"`
+}
+
+// SnippetVariable declares a blank in the code of a snippet, written {{name}}, that is filled in when the snippet
+// is rendered. Type is string (the default), int, bool or enum. Pattern, min and max constrain the length of
+// strings, min and max bound ints, and options lists the values of an enum. A variable without a default must
+// be given a value.
+type SnippetVariable struct {
+	Name        string             `json:"name" binding:"required,max=64"`
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty" binding:"max=500"`
+	Default     *placeholder.Value `json:"default,omitempty" binding:"omitempty,max=10000"`
+	Pattern     string             `json:"pattern,omitempty" binding:"max=500"`
+	Min         *int               `json:"min,omitempty"`
+	Max         *int               `json:"max,omitempty"`
+	Options     []string           `json:"options,omitempty" binding:"max=50,dive,max=200"`
+}
+
+// RenderSnippetRequest holds the values of a snippet's variables. Values may be strings, numbers or booleans.
+type RenderSnippetRequest struct {
+	Values map[string]placeholder.Value `json:"values" binding:"max=20,dive,max=10000"`
+}
+
+// RenderSnippetResponse is the code of a snippet with its variables filled in, and the value used for each one.
+type RenderSnippetResponse struct {
+	Code   string            `json:"code"`
+	Values map[string]string `json:"values"`
+}
+
 type NewSnippetResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Code        string    `json:"code"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	UserID      uuid.UUID `json:"user_id"`
-	CreatedBy   string    `json:"created_by"`
+	ID          uuid.UUID         `json:"id"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Code        string            `json:"code"`
+	Trigger     string            `json:"trigger,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	UserID      uuid.UUID         `json:"user_id"`
+	CreatedBy   string            `json:"created_by"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	Tags        []string          `json:"tags"`
+	Variables   []SnippetVariable `json:"variables,omitempty"`
+	// Version changes on every update. It is also sent as the ETag header.
+	Version int64 `json:"version"`
+}
+
+// Operations of a BulkSnippetRequest
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+	BulkTag    = "tag"
+)
+
+// BulkSnippetRequest runs up to 100 operations in order. Atomic batches are all-or-nothing: the first operation
+// that fails undoes the whole batch. Other batches run every operation and report how each one went.
+type BulkSnippetRequest struct {
+	Atomic     bool                   `json:"atomic"`
+	Operations []BulkSnippetOperation `json:"operations" binding:"required,min=1,max=100,dive"`
+}
+
+// BulkSnippetOperation is one operation of a batch. Create needs snippet, update needs update, and tag adds and
+// removes tags without touching the rest. Every operation but create needs the ID of the snippet, and a non-zero
+// version makes it fail unless the snippet is still at that version, like If-Match.
+type BulkSnippetOperation struct {
+	Op         string                `json:"op" binding:"oneof=create update delete tag"`
+	ID         uuid.UUID             `json:"id" binding:"required_unless=Op create"`
+	Version    int64                 `json:"version,omitempty" binding:"min=0"`
+	Snippet    *NewSnippetRequest    `json:"snippet,omitempty" binding:"required_if=Op create"`
+	Update     *UpdateSnippetRequest `json:"update,omitempty" binding:"required_if=Op update"`
+	AddTags    []string              `json:"add_tags,omitempty" binding:"max=10,dive,max=32"`
+	RemoveTags []string              `json:"remove_tags,omitempty" binding:"max=10,dive,max=32"`
+}
+
+type BulkSnippetResponse struct {
+	Results []BulkSnippetResult `json:"results"`
+}
+
+// BulkSnippetResult reports the outcome of the operation at Index, with the HTTP status it would have had on
+// its own. Snippet is set for successful creates, updates and tag changes, and Error for failures. ID is the
+// nil UUID for creates that failed.
+type BulkSnippetResult struct {
+	Index   int                 `json:"index"`
+	Op      string              `json:"op"`
+	ID      uuid.UUID           `json:"id"`
+	Status  int                 `json:"status"`
+	Snippet *NewSnippetResponse `json:"snippet,omitempty"`
+	Error   *BulkSnippetError   `json:"error,omitempty"`
+}
+
+type BulkSnippetError struct {
+	ErrorMessage string                `json:"error"`
+	Code         apierror.Code         `json:"code"`
+	Details      []apierror.FieldError `json:"details,omitempty"`
+}
+
+// What an import does with a snippet whose title is already used by one of the user's snippets
+const (
+	OnConflictSkip      = "skip"
+	OnConflictOverwrite = "overwrite"
+	OnConflictCreate    = "create"
+)
+
+// ImportSnippetsQuery holds the options of POST /snippets/import. The format must be one of importer.Formats.
+type ImportSnippetsQuery struct {
+	Format     string `form:"format" binding:"required"`
+	DryRun     bool   `form:"dry_run"`
+	OnConflict string `form:"on_conflict" binding:"omitempty,oneof=skip overwrite create"`
+}
+
+// Actions of an ImportItemResult
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportSkip   = "skip"
+	ImportError  = "error"
+)
+
+// ImportItemResult is what an import did, or would do in a dry run, with one snippet found in the files.
+// ConflictsWith is the ID of the snippet with the same title, if there is one.
+type ImportItemResult struct {
+	Source        string                `json:"source"`
+	Title         string                `json:"title,omitempty"`
+	Action        string                `json:"action"`
+	SnippetID     *uuid.UUID            `json:"snippet_id,omitempty"`
+	ConflictsWith *uuid.UUID            `json:"conflicts_with,omitempty"`
+	Reason        string                `json:"reason,omitempty"`
+	Details       []apierror.FieldError `json:"details,omitempty"`
+}
+
+type ImportSnippetsResponse struct {
+	DryRun  bool               `json:"dry_run"`
+	Created int                `json:"created"`
+	Updated int                `json:"updated"`
+	Skipped int                `json:"skipped"`
+	Failed  int                `json:"failed"`
+	Items   []ImportItemResult `json:"items"`
+}
+
+// ExportSnippetsQuery holds the options of GET /users/:username/snippets/export. The format must be one of
+// exporter.Formats.
+type ExportSnippetsQuery struct {
+	Format string `form:"format" binding:"required"`
+	Tag    string `form:"tag"`
+}
+
+// StreamQuery filters the events of the stream. Filters combine, and without any the stream carries every event
+// the subscriber may see.
+type StreamQuery struct {
+	// User is the username of the owner of the snippets
+	User    string `form:"user" binding:"omitempty,max=50"`
+	Tag     string `form:"tag" binding:"omitempty,max=32"`
+	Snippet string `form:"snippet" binding:"omitempty,uuid"`
+	// LastEventID resumes the stream after an event, for WebSocket clients, which cannot send a Last-Event-ID header
+	LastEventID string `form:"last_event_id" binding:"omitempty,max=32"`
+}
+
+// StreamEvent is sent as the data of a server-sent event, or as a WebSocket message.
+type StreamEvent struct {
+	// ID is what Last-Event-ID resumes from
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Data      any        `json:"data,omitempty"`
 }
@@ -0,0 +1,95 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserProfileResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	Website     string    `json:"website"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MeResponse is the profile of the signed-in user, which also includes private fields.
+type MeResponse struct {
+	UserProfileResponse
+	Email               string     `json:"email"`
+	PendingEmail        string     `json:"pending_email,omitempty"`
+	IsActive            bool       `json:"is_active"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	Locale              string     `json:"locale,omitempty"`
+}
+
+// UpdateProfileRequest uses pointers so that omitted fields are left untouched.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=50"`
+	Bio         *string `json:"bio" binding:"omitempty,max=500"`
+	AvatarURL   *string `json:"avatar_url" binding:"omitempty,httpurl,max=2048"`
+	Website     *string `json:"website" binding:"omitempty,httpurl,max=2048"`
+	// Locale is a language tag such as "fr" or "pt-BR"
+	Locale *string `json:"locale" binding:"omitempty,max=35,bcp47_language_tag"`
+}
+
+type PaginatedResponse struct {
+	Items    any   `json:"items"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+	Total    int64 `json:"total"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,max=128"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email,max=254"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	AuthToken int `json:"auth_token" binding:"required,min=1000,max=9999"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// AuditLogQuery holds the filters of GET /admin/audit-log.
+type AuditLogQuery struct {
+	ActorID    string    `form:"actor_id" binding:"omitempty,uuid"`
+	Action     string    `form:"action" binding:"max=64"`
+	TargetType string    `form:"target_type" binding:"omitempty,oneof=user snippet webhook"`
+	TargetID   string    `form:"target_id" binding:"max=64"`
+	IP         string    `form:"ip" binding:"omitempty,ip"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// AccountExport is the profile.json file of a data export archive.
+type AccountExport struct {
+	MeResponse
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// UsageResponse is how much the signed-in user stores, against their quotas. Expired snippets count until they
+// are purged.
+type UsageResponse struct {
+	Snippets QuotaUsage `json:"snippets"`
+	// Bytes is the total size of the code of the snippets
+	Bytes QuotaUsage `json:"bytes"`
+	// MaxSnippetBytes is the largest code a single snippet may have, or zero if unlimited
+	MaxSnippetBytes int64 `json:"max_snippet_bytes"`
+}
+
+// QuotaUsage is a quota and how much of it is used. A zero Limit is unlimited.
+type QuotaUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
@@ -0,0 +1,58 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NewWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,httpurl,max=2048"`
+	Events []string `json:"events" binding:"required,min=1,max=10,dive,oneof=snippet.created snippet.updated snippet.deleted"`
+}
+
+// UpdateWebhookRequest uses pointers so that omitted fields are left untouched. Setting active to true
+// re-enables a webhook that was disabled for failing.
+type UpdateWebhookRequest struct {
+	URL    *string   `json:"url" binding:"omitempty,min=1,httpurl,max=2048"`
+	Events *[]string `json:"events" binding:"omitempty,min=1,max=10,dive,oneof=snippet.created snippet.updated snippet.deleted"`
+	Active *bool     `json:"active"`
+}
+
+type WebhookResponse struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// ConsecutiveFailures counts the failed attempts since the last successful one
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// DisabledAt is set when the webhook was disabled for failing too often
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// Secret is only returned when the webhook is created
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	Event        string     `json:"event"`
+	Payload      string     `json:"payload"`
+	RedeliveryOf *uuid.UUID `json:"redelivery_of,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	// Status is pending, delivered or failed
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	StatusCode    int        `json:"status_code,omitempty"`
+	DurationMS    int64      `json:"duration_ms,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	FailedAt      *time.Time `json:"failed_at,omitempty"`
+}
+
+// SnippetEvent is the data of the snippet.* webhook events. Deleted snippets are sent as they were before
+// they were deleted.
+type SnippetEvent struct {
+	Snippet NewSnippetResponse `json:"snippet"`
+}
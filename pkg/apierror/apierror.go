@@ -0,0 +1,142 @@
+// Package apierror defines the errors returned by the API. Every error has a stable, machine-readable code
+// that maps to an HTTP status; clients should branch on the code, since messages may change.
+//
+// Controllers report errors with c.Error(apierror.New(...)) and return; middleware.ErrorHandler renders
+// them as types.APIErrorMessage.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+)
+
+type Code string
+
+const (
+	CodeBadRequest   Code = "bad_request"
+	CodeInvalidBody  Code = "invalid_body"
+	CodeValidation   Code = "validation_failed"
+	CodeInvalidID    Code = "invalid_id"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeNotFound     Code = "not_found"
+	CodeConflict     Code = "conflict"
+	CodeRateLimited  Code = "rate_limited"
+	CodeInternal     Code = "internal_error"
+
+	CodePreconditionFailed Code = "precondition_failed"
+	CodeUnsupportedMedia   Code = "unsupported_media_type"
+	CodeRequestTooLarge    Code = "request_too_large"
+
+	CodeInvalidCredentials  Code = "invalid_credentials"
+	CodeInvalidToken        Code = "invalid_token"
+	CodeAccountNotActivated Code = "account_not_activated"
+	CodeAccountLocked       Code = "account_locked"
+	CodeWeakPassword        Code = "weak_password"
+	CodeUserExists          Code = "user_exists"
+	CodeUserNotFound        Code = "user_not_found"
+	CodeSnippetNotFound     Code = "snippet_not_found"
+	CodeNotOwner            Code = "not_owner"
+	CodeWebhookNotFound     Code = "webhook_not_found"
+	CodeDeliveryNotFound    Code = "delivery_not_found"
+	CodeInvalidState        Code = "invalid_state"
+	CodeSnippetTooLarge     Code = "snippet_too_large"
+	CodeQuotaExceeded       Code = "quota_exceeded"
+)
+
+var statuses = map[Code]int{
+	CodeBadRequest:   http.StatusBadRequest,
+	CodeInvalidBody:  http.StatusBadRequest,
+	CodeValidation:   http.StatusBadRequest,
+	CodeInvalidID:    http.StatusBadRequest,
+	CodeUnauthorized: http.StatusUnauthorized,
+	CodeForbidden:    http.StatusForbidden,
+	CodeNotFound:     http.StatusNotFound,
+	CodeConflict:     http.StatusConflict,
+	CodeRateLimited:  http.StatusTooManyRequests,
+	CodeInternal:     http.StatusInternalServerError,
+
+	CodePreconditionFailed: http.StatusPreconditionFailed,
+	CodeUnsupportedMedia:   http.StatusUnsupportedMediaType,
+	CodeRequestTooLarge:    http.StatusRequestEntityTooLarge,
+
+	CodeInvalidCredentials:  http.StatusBadRequest,
+	CodeInvalidToken:        http.StatusBadRequest,
+	CodeAccountNotActivated: http.StatusForbidden,
+	CodeAccountLocked:       http.StatusTooManyRequests,
+	CodeWeakPassword:        http.StatusBadRequest,
+	CodeUserExists:          http.StatusConflict,
+	CodeUserNotFound:        http.StatusNotFound,
+	CodeSnippetNotFound:     http.StatusNotFound,
+	CodeNotOwner:            http.StatusForbidden,
+	CodeWebhookNotFound:     http.StatusNotFound,
+	CodeDeliveryNotFound:    http.StatusNotFound,
+	CodeInvalidState:        http.StatusConflict,
+	CodeSnippetTooLarge:     http.StatusRequestEntityTooLarge,
+	CodeQuotaExceeded:       http.StatusForbidden,
+}
+
+// Status returns the HTTP status a code is rendered with.
+func (code Code) Status() int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError describes what is wrong with one field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Code    Code
+	Message string
+	Fields  []FieldError
+	// Err is the underlying cause. It is logged, never sent to the client.
+	Err error
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap attaches the cause of an error, so that it is logged along with the request ID.
+func Wrap(err error, code Code, message string) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Internal is a shorthand for wrapping a server fault.
+func Internal(err error, message string) *Error {
+	return Wrap(err, CodeInternal, message)
+}
+
+// Invalid reports every field of a request that failed validation.
+func Invalid(fields ...FieldError) *Error {
+	return &Error{Code: CodeValidation, Message: "request validation failed", Fields: fields}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Status() int {
+	return e.Code.Status()
+}
+
+// From returns err as an *Error, treating anything else as an internal error.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return Internal(err, "internal server error")
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+)
+
+const (
+	SignIn                = "auth.sign_in"
+	SignInFailed          = "auth.sign_in_failed"
+	SignUp                = "auth.sign_up"
+	AccountActivated      = "auth.account_activated"
+	PasswordResetRequest  = "auth.password_reset_requested"
+	PasswordReset         = "auth.password_reset"
+	AccountLocked         = "auth.account_locked"
+	PasswordChanged       = "account.password_changed"
+	EmailChangeRequested  = "account.email_change_requested"
+	EmailChanged          = "account.email_changed"
+	ProfileUpdated        = "account.profile_updated"
+	AccountDeletion       = "account.deletion_scheduled"
+	AccountDeletionCancel = "account.deletion_cancelled"
+	AccountExported       = "account.exported"
+	SnippetCreated        = "snippet.created"
+	SnippetUpdated        = "snippet.updated"
+	SnippetDeleted        = "snippet.deleted"
+	WebhookCreated        = "webhook.created"
+	WebhookUpdated        = "webhook.updated"
+	WebhookDeleted        = "webhook.deleted"
+)
+
+const (
+	TargetUser    = "user"
+	TargetSnippet = "snippet"
+	TargetWebhook = "webhook"
+)
+
+// Record stores an audit event for the current request. actorID is uuid.Nil when nobody is signed in.
+// Failures are logged rather than returned, so that auditing never breaks the action being audited.
+func Record(c *gin.Context, actorID uuid.UUID, action, targetType, targetID string) {
+	record(models.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}, actorID)
+}
+
+// RecordBackground is Record for actions taken outside a request, such as a collaborative session saving its
+// code. The event has no IP or user agent.
+func RecordBackground(actorID uuid.UUID, action, targetType, targetID string) {
+	record(models.AuditEvent{Action: action, TargetType: targetType, TargetID: targetID}, actorID)
+}
+
+func record(event models.AuditEvent, actorID uuid.UUID) {
+	event.ID = uuid.New()
+	if actorID != uuid.Nil {
+		event.ActorID = &actorID
+	}
+
+	if err := event.Create(); err != nil {
+		log.Printf("failed to record audit event %s: %v", event.Action, err)
+	}
+}
@@ -1,28 +1,29 @@
 package auth
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"math/big"
 
-	"github.com/topboyasante/go-snip/api/v1/models"
-	"github.com/topboyasante/go-snip/internal/database"
-	"gorm.io/gorm"
+	"github.com/topboyasante/go-snip/internal/store"
 )
 
+// GenerateAuthToken returns a random number between 1000 and 9999, for activating accounts, resetting passwords and
+// confirming email changes. The codes guard accounts, so they come from crypto/rand.
 func GenerateAuthToken() int {
-	// Generate a random number between 1000 and 9999
-	randomNumber := rand.Intn(9000) + 1000
-
-	return randomNumber
+	n, err := rand.Int(rand.Reader, big.NewInt(9000))
+	if err != nil {
+		// The system's source of randomness is broken, and no code would be safe to hand out
+		panic(err)
+	}
+	return int(n.Int64()) + 1000
 }
 
-func IsEmailUnique(email string) bool {
-	var user models.User
-	result := database.DB.Where("email = ?", email).First(&user)
-	return result.Error == gorm.ErrRecordNotFound
+func IsEmailUnique(users store.UserStore, email string) bool {
+	_, err := users.GetByEmail(email)
+	return err == store.ErrNotFound
 }
 
-func IsUsernameUnique(username string) bool {
-	var user models.User
-	result := database.DB.Where("username = ?", username).First(&user)
-	return result.Error == gorm.ErrRecordNotFound
+func IsUsernameUnique(users store.UserStore, username string) bool {
+	_, err := users.GetByUsername(username)
+	return err == store.ErrNotFound
 }
@@ -1,19 +1,34 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/topboyasante/go-snip/pkg/config"
 )
 
-func CreateJWTTokens(data any) (string, string, error) {
+// Token types, stored in the "typ" claim so that a refresh token cannot be used as an access token.
+// Tokens issued before the claim existed are treated as access tokens.
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// CreateJWTTokens returns an access token and a refresh token for the user, stamped with their token version.
+func CreateJWTTokens(userID uuid.UUID, tokenVersion int) (string, string, error) {
 	accessTokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": data,                                       // Subject (user identifier)
-		"iss": "invxice",                                  // Issuer
-		"aud": data,                                       // Audience (user role)
+		"sub": userID,                                    // Subject (user identifier)
+		"iss": "invxice",                                 // Issuer
+		"aud": userID,                                    // Audience (user role)
 		"exp": time.Now().Add(time.Hour * 24 * 1).Unix(), // Expiration time = 1 day
-		"iat": time.Now().Unix(),                          // Issued at
+		"iat": time.Now().Unix(),                         // Issued at
+		"typ": AccessToken,                               // Token type
+		"ver": tokenVersion,                              // Token version of the user
 	})
 
 	accessTokenString, err := accessTokenClaims.SignedString([]byte(config.ENV.JWTKey))
@@ -22,11 +37,13 @@ func CreateJWTTokens(data any) (string, string, error) {
 	}
 
 	refreshTokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": data,                                       // Subject (user identifier)
+		"sub": userID,                                     // Subject (user identifier)
 		"iss": "invxice",                                  // Issuer
-		"aud": data,                                       // Audience (user role)
+		"aud": userID,                                     // Audience (user role)
 		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(), // Expiration time = 30 days
 		"iat": time.Now().Unix(),                          // Issued at
+		"typ": RefreshToken,                               // Token type
+		"ver": tokenVersion,                               // Token version of the user
 	})
 
 	refreshTokenString, err := refreshTokenClaims.SignedString([]byte(config.ENV.JWTKey))
@@ -36,3 +53,39 @@ func CreateJWTTokens(data any) (string, string, error) {
 
 	return accessTokenString, refreshTokenString, nil
 }
+
+// ParseJWTToken verifies a token of the given type and returns the ID of the user it was issued for, along with
+// their token version then. Tokens issued before the "ver" claim existed have version 0.
+func ParseJWTToken(tokenStr, tokenType string) (uuid.UUID, int, error) {
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return []byte(config.ENV.JWTKey), nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return uuid.Nil, 0, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return uuid.Nil, 0, ErrInvalidToken
+	}
+
+	typ, _ := claims["typ"].(string)
+	if typ == "" {
+		typ = AccessToken
+	}
+	if typ != tokenType {
+		return uuid.Nil, 0, fmt.Errorf("%w: expected an %s token, got a %s token", ErrInvalidToken, tokenType, typ)
+	}
+
+	userID, err := uuid.Parse(fmt.Sprint(claims["sub"]))
+	if err != nil {
+		return uuid.Nil, 0, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	// Numbers in claims are decoded as float64
+	version, _ := claims["ver"].(float64)
+	return userID, int(version), nil
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/topboyasante/go-snip/internal/types"
+)
+
+// SignIn exchanges a username and password for tokens, and uses them for later requests.
+func (c *Client) SignIn(ctx context.Context, username, password string) (UserResponse, error) {
+	var user UserResponse
+	err := c.do(ctx, http.MethodPost, "/auth/sign-in/", nil, UserLoginRequest{
+		Username: username,
+		Password: password,
+	}, &user)
+	if err != nil {
+		return user, err
+	}
+
+	c.SetTokens(user.AccessToken, user.RefeshToken)
+	return user, nil
+}
+
+// SignUp creates an account, which must be activated with the code emailed to the user.
+func (c *Client) SignUp(ctx context.Context, req UserSignUpRequest) (MeResponse, error) {
+	var user MeResponse
+	err := c.do(ctx, http.MethodPost, "/auth/sign-up/", nil, req, &user)
+	return user, err
+}
+
+func (c *Client) ActivateAccount(ctx context.Context, req ActivateAccountRequest) error {
+	return c.do(ctx, http.MethodPost, "/auth/activate-account", nil, req, nil)
+}
+
+// ForgotPassword emails a code to reset the password with.
+func (c *Client) ForgotPassword(ctx context.Context, email string) error {
+	return c.do(ctx, http.MethodPost, "/auth/forgot-password", nil, types.ForgotPasswordRequest{Email: email}, nil)
+}
+
+func (c *Client) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	return c.do(ctx, http.MethodPost, "/auth/reset-password", nil, req, nil)
+}
+
+// RefreshToken exchanges the refresh token for new tokens straight away. Requests refresh them on their own
+// when the access token has expired, so this is only needed to rotate them early.
+func (c *Client) RefreshToken(ctx context.Context) (TokenResponse, error) {
+	if c.Tokens().RefreshToken == "" {
+		return TokenResponse{}, errors.New("client has no refresh token")
+	}
+	if err := c.refresh(ctx, c.Tokens().AccessToken); err != nil {
+		return TokenResponse{}, err
+	}
+	return c.Tokens(), nil
+}
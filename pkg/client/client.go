@@ -0,0 +1,297 @@
+// Package client is a Go client for the go-snip /api/v1 routes.
+//
+// Requests that fail with a 401 are retried once after refreshing the access token, when the client has a
+// refresh token. Idempotent requests that fail with a network error or a 502, 503 or 504 are retried with
+// exponential backoff, as are requests rejected with a 429 that asks for a short enough wait.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/topboyasante/go-snip/internal/types"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultMinBackoff = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onRefresh  func(TokenResponse)
+
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+
+	// refreshMu makes concurrent requests that hit a 401 share a single refresh
+	refreshMu sync.Mutex
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the default http.Client, which times out after 30 seconds.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a failed request is retried. Zero disables retries.
+func WithRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithBackoff sets the delay before the first retry, which doubles on every retry up to max.
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff, c.maxBackoff = min, max
+	}
+}
+
+// WithTokens starts the client with tokens from an earlier sign in.
+func WithTokens(accessToken, refreshToken string) Option {
+	return func(c *Client) {
+		c.accessToken, c.refreshToken = accessToken, refreshToken
+	}
+}
+
+// OnTokenRefresh is called with the new tokens whenever the client refreshes them, so that they can be saved.
+func OnTokenRefresh(fn func(TokenResponse)) Option {
+	return func(c *Client) {
+		c.onRefresh = fn
+	}
+}
+
+// New returns a client for the server at baseURL, e.g. "http://localhost:4000".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/") + "/api/v1",
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SetTokens replaces the tokens sent with every request. SignIn and token refreshes set them as well.
+func (c *Client) SetTokens(accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken, c.refreshToken = accessToken, refreshToken
+}
+
+// Tokens returns the current access and refresh tokens.
+func (c *Client) Tokens() TokenResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return TokenResponse{AccessToken: c.accessToken, RefreshToken: c.refreshToken}
+}
+
+// do sends body as JSON and decodes the data field of the response into out, when out is not nil. An out of
+// type *[]byte receives the raw response body instead, for responses that are files.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	return c.doWithHeader(ctx, method, path, query, nil, body, out)
+}
+
+// doWithHeader is do with extra request headers, which replace the defaults.
+func (c *Client) doWithHeader(ctx context.Context, method, path string, query url.Values, header http.Header, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	return c.doPayload(ctx, method, path, query, header, payload, out)
+}
+
+// doPayload sends a body that is already encoded, such as a multipart form, whose Content-Type is in header.
+func (c *Client) doPayload(ctx context.Context, method, path string, query url.Values, header http.Header, payload []byte, out any) error {
+	tokens := c.Tokens()
+	err := c.send(ctx, method, path, query, header, payload, tokens.AccessToken, out)
+
+	// The auth routes answer 401 for bad credentials, not for an expired token
+	if !errors.Is(err, ErrUnauthorized) || tokens.RefreshToken == "" || strings.HasPrefix(path, "/auth/") {
+		return err
+	}
+	if refreshErr := c.refresh(ctx, tokens.AccessToken); refreshErr != nil {
+		return err
+	}
+	return c.send(ctx, method, path, query, header, payload, c.Tokens().AccessToken, out)
+}
+
+// refresh exchanges the refresh token for new tokens, unless another request already replaced staleToken.
+func (c *Client) refresh(ctx context.Context, staleToken string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	tokens := c.Tokens()
+	if tokens.AccessToken != staleToken {
+		return nil
+	}
+
+	payload, err := json.Marshal(types.RefreshTokenRequest{RefreshToken: tokens.RefreshToken})
+	if err != nil {
+		return err
+	}
+
+	var refreshed TokenResponse
+	if err := c.send(ctx, http.MethodPost, "/auth/refresh-token", nil, nil, payload, "", &refreshed); err != nil {
+		return err
+	}
+
+	c.SetTokens(refreshed.AccessToken, refreshed.RefreshToken)
+	if c.onRefresh != nil {
+		c.onRefresh(refreshed)
+	}
+	return nil
+}
+
+// send makes a request, retrying it as described in the package documentation.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, header http.Header, payload []byte, token string, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.attempt(ctx, method, u, header, payload, token, out)
+		if err == nil || attempt >= c.maxRetries {
+			return err
+		}
+
+		delay, ok := c.retryDelay(method, attempt, err)
+		if !ok {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, u string, header http.Header, payload []byte, token string, out any) error {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return newError(res)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		if *raw, err = io.ReadAll(res.Body); err != nil {
+			return fmt.Errorf("reading %s %s response: %w", method, req.URL.Path, err)
+		}
+		return nil
+	}
+
+	envelope := struct {
+		Data any `json:"data"`
+	}{Data: out}
+	if err := json.NewDecoder(res.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("decoding %s %s response: %w", method, req.URL.Path, err)
+	}
+	return nil
+}
+
+// retryDelay reports whether a failed attempt should be retried, and after how long.
+func (c *Client) retryDelay(method string, attempt int, err error) (time.Duration, bool) {
+	backoff := c.minBackoff << attempt
+	if backoff <= 0 || backoff > c.maxBackoff {
+		backoff = c.maxBackoff
+	}
+	// Full jitter, so that clients that failed together do not retry together
+	backoff = time.Duration(rand.Int63n(int64(backoff) + 1))
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		// A network error may have happened after the server acted on the request
+		return backoff, idempotent(method) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		// The server did not act on the request, but there is no point waiting minutes for a lockout to end
+		if apiErr.RetryAfter > c.maxBackoff {
+			return 0, false
+		}
+		return max(backoff, apiErr.RetryAfter), true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return max(backoff, apiErr.RetryAfter), idempotent(method)
+	default:
+		return 0, false
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds, which is the form the server sends.
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/topboyasante/go-snip/internal/types"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+)
+
+// Error is returned for every response outside the 2xx range, decoded from its types.APIErrorMessage.
+// Compare it to the sentinel errors below with errors.Is, or switch on Code.
+type Error struct {
+	StatusCode int
+	Code       apierror.Code
+	Message    string
+	Details    []apierror.FieldError
+	RequestID  string
+	// RetryAfter is set when the server asked the client to wait before trying again
+	RetryAfter time.Duration
+}
+
+var (
+	ErrBadRequest      = &Error{StatusCode: http.StatusBadRequest}
+	ErrUnauthorized    = &Error{StatusCode: http.StatusUnauthorized}
+	ErrForbidden       = &Error{StatusCode: http.StatusForbidden}
+	ErrNotFound        = &Error{StatusCode: http.StatusNotFound}
+	ErrConflict        = &Error{StatusCode: http.StatusConflict}
+	ErrTooManyRequests = &Error{StatusCode: http.StatusTooManyRequests}
+	// ErrPreconditionFailed is returned when a conditional write finds that the snippet changed since it was read
+	ErrPreconditionFailed = &Error{StatusCode: http.StatusPreconditionFailed}
+	// ErrServer matches every 5xx response
+	ErrServer = &Error{StatusCode: http.StatusInternalServerError}
+)
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.StatusCode)
+	}
+	return e.Message
+}
+
+// Is matches a target with the same status code, and the same error code if the target has one, so that
+// errors.Is(err, &client.Error{StatusCode: 409, Code: apierror.CodeUserExists}) works as expected.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	if t == ErrServer {
+		return e.StatusCode >= 500
+	}
+	return t.StatusCode == e.StatusCode && (t.Code == "" || t.Code == e.Code)
+}
+
+func newError(res *http.Response) *Error {
+	var apiErr types.APIErrorMessage
+	// Proxies in front of the server may answer without a body
+	json.NewDecoder(res.Body).Decode(&apiErr)
+
+	return &Error{
+		StatusCode: res.StatusCode,
+		Code:       apiErr.Code,
+		Message:    apiErr.ErrorMessage,
+		Details:    apiErr.Details,
+		RequestID:  apiErr.RequestID,
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+	}
+}
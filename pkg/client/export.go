@@ -0,0 +1,19 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ExportSnippets downloads the snippets of a user in the format of an editor or text expander: vscode, sublime,
+// ultisnips, espanso or jetbrains. The sublime format is a zip archive. An empty tag exports every snippet.
+func (c *Client) ExportSnippets(ctx context.Context, username, format, tag string) ([]byte, error) {
+	query := tagQuery(tag)
+	query.Set("format", format)
+
+	var data []byte
+	header := http.Header{"Accept": {"*/*"}}
+	err := c.doWithHeader(ctx, http.MethodGet, "/users/"+url.PathEscape(username)+"/snippets/export", query, header, nil, &data)
+	return data, err
+}
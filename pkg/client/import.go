@@ -0,0 +1,72 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/topboyasante/go-snip/internal/types"
+)
+
+type (
+	ImportSnippetsResponse = types.ImportSnippetsResponse
+	ImportItemResult       = types.ImportItemResult
+)
+
+// What an import does with a snippet whose title is already taken
+const (
+	OnConflictSkip      = types.OnConflictSkip
+	OnConflictOverwrite = types.OnConflictOverwrite
+	OnConflictCreate    = types.OnConflictCreate
+)
+
+// ImportOptions selects the format of an import and what it does with snippets whose title is already taken.
+type ImportOptions struct {
+	// Format is gist, vscode, jetbrains or dir
+	Format string
+	// DryRun reports what the import would do without saving anything
+	DryRun bool
+	// OnConflict defaults to OnConflictSkip
+	OnConflict string
+}
+
+// ImportFile is a file to import. For the dir format, the name is the title of the snippet, so it should be the
+// path of the file relative to the imported directory.
+type ImportFile struct {
+	Name string
+	Data []byte
+}
+
+// ImportSnippets uploads files in the format of another tool and imports the snippets in them.
+func (c *Client) ImportSnippets(ctx context.Context, opts ImportOptions, files []ImportFile) (ImportSnippetsResponse, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, file := range files {
+		part, err := form.CreateFormFile("files", file.Name)
+		if err != nil {
+			return ImportSnippetsResponse{}, err
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return ImportSnippetsResponse{}, err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return ImportSnippetsResponse{}, err
+	}
+
+	query := url.Values{"format": {opts.Format}}
+	if opts.DryRun {
+		query.Set("dry_run", strconv.FormatBool(true))
+	}
+	if opts.OnConflict != "" {
+		query.Set("on_conflict", opts.OnConflict)
+	}
+
+	var res ImportSnippetsResponse
+	header := http.Header{"Content-Type": {form.FormDataContentType()}}
+	err := c.doPayload(ctx, http.MethodPost, "/snippets/import", query, header, body.Bytes(), &res)
+	return res, err
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/internal/types"
+)
+
+// SnippetPage is a page of snippets, newest first.
+type SnippetPage struct {
+	Items    []Snippet `json:"items"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"page_size"`
+	Total    int64     `json:"total"`
+}
+
+func (c *Client) CreateSnippet(ctx context.Context, req NewSnippetRequest) (Snippet, error) {
+	var snippet Snippet
+	err := c.do(ctx, http.MethodPost, "/snippets/create", nil, req, &snippet)
+	return snippet, err
+}
+
+func (c *Client) GetSnippet(ctx context.Context, id uuid.UUID) (Snippet, error) {
+	var snippet Snippet
+	err := c.do(ctx, http.MethodGet, "/snippets/"+id.String(), nil, nil, &snippet)
+	return snippet, err
+}
+
+// ListSnippets returns every snippet, or only those with the given tag when it is not empty.
+func (c *Client) ListSnippets(ctx context.Context, tag string) ([]Snippet, error) {
+	var snippets []Snippet
+	err := c.do(ctx, http.MethodGet, "/snippets", tagQuery(tag), nil, &snippets)
+	return snippets, err
+}
+
+// ListUserSnippets returns a page of the snippets of a user. Zero page and pageSize use the server defaults.
+func (c *Client) ListUserSnippets(ctx context.Context, username, tag string, page, pageSize int) (SnippetPage, error) {
+	query := tagQuery(tag)
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		query.Set("page_size", strconv.Itoa(pageSize))
+	}
+
+	var snippets SnippetPage
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(username)+"/snippets", query, nil, &snippets)
+	return snippets, err
+}
+
+func (c *Client) UpdateSnippet(ctx context.Context, id uuid.UUID, req UpdateSnippetRequest) error {
+	return c.do(ctx, http.MethodPut, "/snippets/"+id.String(), nil, req, nil)
+}
+
+// SnippetPatch is a JSON Merge Patch of a snippet: only its keys are changed, and a nil value clears
+// description, trigger, expires_at, tags or variables.
+type SnippetPatch map[string]any
+
+// PatchSnippet applies a patch to a snippet and returns the result. A non-zero version makes the patch conditional:
+// it fails with ErrPreconditionFailed if the snippet is no longer at that version.
+func (c *Client) PatchSnippet(ctx context.Context, id uuid.UUID, version int64, patch SnippetPatch) (Snippet, error) {
+	header := http.Header{"Content-Type": {"application/merge-patch+json"}}
+	if version != 0 {
+		header.Set("If-Match", `"`+strconv.FormatInt(version, 10)+`"`)
+	}
+
+	var snippet Snippet
+	err := c.doWithHeader(ctx, http.MethodPatch, "/snippets/"+id.String(), nil, header, patch, &snippet)
+	return snippet, err
+}
+
+func (c *Client) DeleteSnippet(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/snippets/"+id.String(), nil, nil, nil)
+}
+
+// RenderSnippet fills in the variables of a snippet. Variables that are not in values take their defaults.
+func (c *Client) RenderSnippet(ctx context.Context, id uuid.UUID, values map[string]string) (RenderedSnippet, error) {
+	var rendered RenderedSnippet
+	body := struct {
+		Values map[string]string `json:"values"`
+	}{Values: values}
+	err := c.do(ctx, http.MethodPost, "/snippets/"+id.String()+"/render", nil, body, &rendered)
+	return rendered, err
+}
+
+// BulkSnippets runs a batch of operations. Atomic batches fail with the error of the first operation that failed,
+// and make no changes. Other batches return the result of every operation, each of which may have failed.
+func (c *Client) BulkSnippets(ctx context.Context, req BulkSnippetRequest) ([]BulkSnippetResult, error) {
+	var res types.BulkSnippetResponse
+	err := c.do(ctx, http.MethodPost, "/snippets/bulk", nil, req, &res)
+	return res.Results, err
+}
+
+func tagQuery(tag string) url.Values {
+	query := url.Values{}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	return query
+}
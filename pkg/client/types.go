@@ -0,0 +1,34 @@
+package client
+
+import "github.com/topboyasante/go-snip/internal/types"
+
+// The request and response types of the API, re-exported because code outside this module cannot import
+// internal/types.
+type (
+	UserLoginRequest       = types.UserLoginRequest
+	UserSignUpRequest      = types.UserSignUpRequest
+	ActivateAccountRequest = types.ActivateAccountRequest
+	ResetPasswordRequest   = types.ResetPasswordRequest
+	UserResponse           = types.UserResponse
+	MeResponse             = types.MeResponse
+	TokenResponse          = types.TokenResponse
+
+	NewSnippetRequest    = types.NewSnippetRequest
+	UpdateSnippetRequest = types.UpdateSnippetRequest
+	Snippet              = types.NewSnippetResponse
+	SnippetVariable      = types.SnippetVariable
+	RenderedSnippet      = types.RenderSnippetResponse
+
+	BulkSnippetRequest   = types.BulkSnippetRequest
+	BulkSnippetOperation = types.BulkSnippetOperation
+	BulkSnippetResult    = types.BulkSnippetResult
+	BulkSnippetError     = types.BulkSnippetError
+)
+
+// Operations of a BulkSnippetOperation
+const (
+	BulkCreate = types.BulkCreate
+	BulkUpdate = types.BulkUpdate
+	BulkDelete = types.BulkDelete
+	BulkTag    = types.BulkTag
+)
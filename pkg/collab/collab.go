@@ -0,0 +1,556 @@
+// Package collab runs the sessions in which several people edit the code of a snippet at once. Edits are
+// operations of package ot: the session orders them into revisions, transforming those made against an older
+// revision past the ones since, in the way of ot.js. Clients do the same with the operations they receive while
+// their own are unacknowledged, so everyone converges on the same code.
+//
+// The session also tracks the participants and their cursors, and checkpoints the code into the snippet every
+// so often and when the last participant leaves. Sessions live in the memory of the process that started them.
+package collab
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/internal/store"
+	"github.com/topboyasante/go-snip/pkg/ot"
+)
+
+// Types of the messages of a session
+const (
+	// Joined is the first message a participant receives, with the code and who else is there
+	Joined = "joined"
+	// Op carries an operation, from a client that made it against Revision, or from the session to the other
+	// participants once it is applied as Revision
+	Op = "op"
+	// Ack tells a client its operation was applied as Revision
+	Ack = "ack"
+	// Cursor carries the cursor of a participant, from its client or to the others
+	Cursor = "cursor"
+	// Presence lists the participants whenever one joins or leaves
+	Presence = "presence"
+	// Checkpoint tells participants the code at Revision was saved as Version of the snippet
+	Checkpoint = "checkpoint"
+	// Error reports an operation or cursor the session rejected
+	Error = "error"
+	// Closed ends the session for everyone
+	Closed = "closed"
+)
+
+// Message is what clients and sessions send each other. Messages from the session carry the current revision,
+// except for checkpoints, which carry the revision that was saved.
+type Message struct {
+	Type         string        `json:"type"`
+	Revision     int           `json:"revision"`
+	Op           *ot.Operation `json:"op,omitempty"`
+	Cursor       *Selection    `json:"cursor,omitempty"`
+	Participant  string        `json:"participant,omitempty"`
+	Code         *string       `json:"code,omitempty"`
+	Version      int64         `json:"version,omitempty"`
+	You          *Participant  `json:"you,omitempty"`
+	Participants []Participant `json:"participants,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// Selection is a cursor, from Anchor to Head. Both are equal when nothing is selected.
+type Selection struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// Participant is a connection to a session. The same user may take part from several connections.
+type Participant struct {
+	ID       string     `json:"id"`
+	UserID   uuid.UUID  `json:"user_id"`
+	Username string     `json:"username"`
+	CanEdit  bool       `json:"can_edit"`
+	Cursor   *Selection `json:"cursor,omitempty"`
+}
+
+// Storage loads and checkpoints the code of snippets.
+type Storage interface {
+	// Load returns the code of a snippet and its version.
+	Load(snippetID uuid.UUID) (code string, version int64, err error)
+	// Save replaces the code of a snippet if it is still at version, and returns its new version. editor is the
+	// user who made the latest edit to the code. It returns store.ErrConflict if the snippet changed since,
+	// store.ErrNotFound if it is gone, and a *RejectedError if the code cannot be saved as it is.
+	Save(snippetID uuid.UUID, code string, version int64, editor uuid.UUID) (int64, error)
+}
+
+// RejectedError is returned by Storage.Save for code that is refused however often it is saved, such as code
+// over a size limit or quota. The session stops checkpointing until the code changes, and shows Reason to the
+// participants.
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return e.Reason
+}
+
+// Hub holds the sessions of a process, one per snippet being edited.
+type Hub struct {
+	storage Storage
+	// CheckpointInterval is how often the code of a session is saved while it changes
+	CheckpointInterval time.Duration
+	// MaxBytes is the largest code that edits may produce, or zero for no limit
+	MaxBytes int
+	// MaxHistory is how many operations a session remembers for clients that are behind. Clients further
+	// behind are disconnected, and join again.
+	MaxHistory int
+
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*session
+}
+
+// NewHub returns a hub whose sessions load and checkpoint code in storage.
+func NewHub(storage Storage, checkpointInterval time.Duration, maxBytes int) *Hub {
+	return &Hub{
+		storage:            storage,
+		CheckpointInterval: checkpointInterval,
+		MaxBytes:           maxBytes,
+		MaxHistory:         1000,
+		sessions:           make(map[uuid.UUID]*session),
+	}
+}
+
+// session is the state of the code of a snippet being edited.
+type session struct {
+	hub       *Hub
+	snippetID uuid.UUID
+
+	// checkpointMu runs one checkpoint at a time. It is taken before mu, which is released while saving.
+	checkpointMu sync.Mutex
+
+	mu   sync.Mutex
+	code string
+	// editor is the user of the participant who made the latest edit
+	editor uuid.UUID
+	// revision counts the operations applied since the session started, and history holds the latest of them:
+	// history[i] turned revision historyStart+i into the next one.
+	revision     int
+	history      []ot.Operation
+	historyStart int
+	// savedCode is the code as of version of the snippet, the last one the session loaded or saved
+	savedCode string
+	version   int64
+	// rejectedCode is the code the storage last refused to save, which is not tried again
+	rejectedCode *string
+	conns        map[*Conn]struct{}
+	closed       bool
+	// done is closed once the session ended and its code was checkpointed for the last time
+	done chan struct{}
+}
+
+// Conn is the connection of a participant to a session.
+type Conn struct {
+	session     *session
+	participant Participant
+	send        chan Message
+	// kicked is set once send is closed, which must only happen with the lock of the session held
+	kicked bool
+}
+
+// Join adds a participant to the session of a snippet, starting it if needed. The participant must then read
+// the messages of the session from Messages, pass those of its client to Handle, and call Leave when it is done.
+func (h *Hub) Join(snippetID uuid.UUID, p Participant) (*Conn, error) {
+	p.Cursor = nil
+	conn := &Conn{participant: p, send: make(chan Message, 256)}
+
+	// A session whose last participant is leaving may still be listed, and cannot be joined
+	if h.join(snippetID, conn) {
+		return conn, nil
+	}
+
+	// Loading is done without the lock, so that joining one session does not wait on another
+	code, version, err := h.storage.Load(snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Someone else may have started the session in the meantime
+	if s, ok := h.sessions[snippetID]; ok && s.add(conn) {
+		return conn, nil
+	}
+	s := &session{
+		hub:       h,
+		snippetID: snippetID,
+		code:      code,
+		savedCode: code,
+		version:   version,
+		conns:     make(map[*Conn]struct{}),
+		done:      make(chan struct{}),
+	}
+	h.sessions[snippetID] = s
+	s.add(conn)
+	go s.checkpointEvery(h.CheckpointInterval)
+	return conn, nil
+}
+
+// join adds a connection to the running session of a snippet, and reports false if there is none. A session that
+// is ending is waited for, so that the code is loaded after its last checkpoint.
+func (h *Hub) join(snippetID uuid.UUID, conn *Conn) bool {
+	h.mu.Lock()
+	s, ok := h.sessions[snippetID]
+	if ok && s.add(conn) {
+		h.mu.Unlock()
+		return true
+	}
+	h.mu.Unlock()
+
+	if ok {
+		<-s.done
+	}
+	return false
+}
+
+// Participant returns the participant of the connection.
+func (c *Conn) Participant() Participant {
+	return c.participant
+}
+
+// Messages returns the messages for the client. It is closed once the participant leaves, or when the session
+// drops it for falling behind or sending something it cannot apply.
+func (c *Conn) Messages() <-chan Message {
+	return c.send
+}
+
+// Handle applies a message from the client.
+func (c *Conn) Handle(msg Message) {
+	s := c.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.kicked || s.closed {
+		return
+	}
+
+	switch msg.Type {
+	case Op:
+		if msg.Op == nil {
+			c.sendLocked(Message{Type: Error, Error: "op messages need an op"})
+			return
+		}
+		s.applyLocked(c, msg.Revision, *msg.Op, msg.Cursor)
+	case Cursor:
+		if msg.Cursor != nil && !s.validSelection(*msg.Cursor) {
+			c.sendLocked(Message{Type: Error, Error: "the cursor is outside the code"})
+			return
+		}
+		c.participant.Cursor = msg.Cursor
+		s.broadcastLocked(c, Message{Type: Cursor, Participant: c.participant.ID, Cursor: msg.Cursor})
+	default:
+		c.sendLocked(Message{Type: Error, Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+	}
+}
+
+// Leave removes the participant from the session. When the last one leaves, the code is checkpointed and the
+// session ends. Leave may be called more than once.
+func (c *Conn) Leave() {
+	s := c.session
+	s.mu.Lock()
+	if _, ok := s.conns[c]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.conns, c)
+	c.kickLocked()
+
+	last := len(s.conns) == 0 && !s.closed
+	if last {
+		s.closed = true
+	} else {
+		s.broadcastLocked(nil, s.presenceLocked())
+	}
+	s.mu.Unlock()
+
+	if last {
+		if err := s.checkpoint(); err != nil {
+			log.Printf("failed to checkpoint collaborative session of snippet %s: %v", s.snippetID, err)
+		}
+		s.hub.remove(s)
+	}
+}
+
+// remove forgets a session that ended, once it will not be checkpointed again.
+func (h *Hub) remove(s *session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sessions[s.snippetID] == s {
+		delete(h.sessions, s.snippetID)
+	}
+	close(s.done)
+}
+
+// add adds a connection to the session, unless it is closed.
+func (s *session) add(c *Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+
+	c.session = s
+	s.conns[c] = struct{}{}
+
+	code := s.code
+	presence := s.presenceLocked()
+	c.sendLocked(Message{
+		Type:         Joined,
+		Code:         &code,
+		Version:      s.version,
+		You:          &c.participant,
+		Participants: presence.Participants,
+	})
+	s.broadcastLocked(c, presence)
+	return true
+}
+
+// applyLocked transforms an operation that a client made against revision into one on the current code, applies
+// it, and sends it to everyone else.
+func (s *session) applyLocked(c *Conn, revision int, op ot.Operation, cursor *Selection) {
+	if !c.participant.CanEdit {
+		c.sendLocked(Message{Type: Error, Error: "you can only view this snippet"})
+		return
+	}
+	if revision < s.historyStart || revision > s.revision {
+		// The client cannot recover without the operations it missed, so it has to join again
+		c.sendLocked(Message{Type: Error, Error: "the revision is no longer available, join the session again"})
+		c.kickLocked()
+		return
+	}
+
+	// The cursor comes after the operation on the client, so it moves past what the client had not seen yet
+	for _, concurrent := range s.history[revision-s.historyStart:] {
+		var err error
+		if op, concurrent, err = ot.Transform(op, concurrent); err != nil {
+			c.sendLocked(Message{Type: Error, Error: "the operation does not apply to that revision"})
+			c.kickLocked()
+			return
+		}
+		if cursor != nil {
+			cursor = &Selection{Anchor: concurrent.TransformIndex(cursor.Anchor), Head: concurrent.TransformIndex(cursor.Head)}
+		}
+	}
+
+	code, err := op.Apply(s.code)
+	if err != nil {
+		c.sendLocked(Message{Type: Error, Error: "the operation does not apply to that revision"})
+		c.kickLocked()
+		return
+	}
+	if max := s.hub.MaxBytes; max > 0 && len(code) > max && len(code) > len(s.code) {
+		// The client already applied the operation, so it has to join again to undo it
+		c.sendLocked(Message{Type: Error, Error: fmt.Sprintf("code cannot be larger than %d bytes", s.hub.MaxBytes)})
+		c.kickLocked()
+		return
+	}
+
+	s.commitLocked(op, code, c)
+	if cursor != nil && s.validSelection(*cursor) {
+		c.participant.Cursor = cursor
+		s.broadcastLocked(c, Message{Type: Cursor, Participant: c.participant.ID, Cursor: cursor})
+	}
+}
+
+// commitLocked makes an operation the next revision. author is the connection it came from, or nil for changes
+// made outside the session.
+func (s *session) commitLocked(op ot.Operation, code string, author *Conn) {
+	s.code = code
+	if author != nil {
+		s.editor = author.participant.UserID
+	}
+	s.history = append(s.history, op)
+	s.revision++
+	if excess := len(s.history) - s.hub.MaxHistory; excess > 0 {
+		s.history = append(s.history[:0], s.history[excess:]...)
+		s.historyStart += excess
+	}
+
+	for conn := range s.conns {
+		if cur := conn.participant.Cursor; cur != nil {
+			conn.participant.Cursor = &Selection{Anchor: op.TransformIndex(cur.Anchor), Head: op.TransformIndex(cur.Head)}
+		}
+	}
+
+	if author != nil {
+		author.sendLocked(Message{Type: Ack})
+	}
+	participant := ""
+	if author != nil {
+		participant = author.participant.ID
+	}
+	s.broadcastLocked(author, Message{Type: Op, Op: &op, Participant: participant})
+}
+
+func (s *session) validSelection(sel Selection) bool {
+	n := utf8.RuneCountInString(s.code)
+	return sel.Anchor >= 0 && sel.Anchor <= n && sel.Head >= 0 && sel.Head <= n
+}
+
+func (s *session) presenceLocked() Message {
+	participants := make([]Participant, 0, len(s.conns))
+	for conn := range s.conns {
+		participants = append(participants, conn.participant)
+	}
+	return Message{Type: Presence, Participants: participants}
+}
+
+// checkpointEvery checkpoints the code until the session ends.
+func (s *session) checkpointEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		closed := s.closed
+		s.mu.Unlock()
+		if closed {
+			return
+		}
+
+		err := s.checkpoint()
+
+		s.mu.Lock()
+		// The last participant may have left while the code was saved, which ends the session
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		var rejected *RejectedError
+		if errors.Is(err, store.ErrNotFound) {
+			s.closeLocked("the snippet no longer exists")
+		} else if errors.As(err, &rejected) {
+			s.broadcastLocked(nil, Message{Type: Error, Error: "the code cannot be saved: " + rejected.Reason})
+		} else if err != nil {
+			log.Printf("failed to checkpoint collaborative session of snippet %s: %v", s.snippetID, err)
+			s.broadcastLocked(nil, Message{Type: Error, Error: "the code could not be saved, and will be retried"})
+		}
+		closed = s.closed
+		s.mu.Unlock()
+
+		if closed {
+			s.hub.remove(s)
+			return
+		}
+	}
+}
+
+// checkpoint saves the code if it changed since the last checkpoint. The code is copied under the lock and saved
+// without it, so that edits are not held up by the storage. If the snippet was updated in the meantime, the change
+// to its code is merged into the session as if a participant made it, and saving is tried again.
+func (s *session) checkpoint() error {
+	s.checkpointMu.Lock()
+	defer s.checkpointMu.Unlock()
+
+	for attempt := 0; attempt < 3; attempt++ {
+		s.mu.Lock()
+		code, revision, version, editor := s.code, s.revision, s.version, s.editor
+		// Snippets cannot be saved without code, so a session that cleared it keeps the last checkpoint
+		unchanged := code == s.savedCode || strings.TrimSpace(code) == "" ||
+			(s.rejectedCode != nil && *s.rejectedCode == code)
+		s.mu.Unlock()
+		if unchanged {
+			return nil
+		}
+
+		saved, err := s.hub.storage.Save(s.snippetID, code, version, editor)
+		if err == nil {
+			s.mu.Lock()
+			s.savedCode, s.version = code, saved
+			s.rejectedCode = nil
+			s.broadcastLocked(nil, Message{Type: Checkpoint, Revision: revision, Version: saved})
+			s.mu.Unlock()
+			return nil
+		}
+		var rejected *RejectedError
+		if errors.As(err, &rejected) {
+			s.mu.Lock()
+			s.rejectedCode = &code
+			s.mu.Unlock()
+		}
+		if !errors.Is(err, store.ErrConflict) {
+			return err
+		}
+
+		stored, version, err := s.hub.storage.Load(s.snippetID)
+		if err != nil {
+			return err
+		}
+		if err := s.merge(stored, version); err != nil {
+			return err
+		}
+	}
+	return errors.New("the snippet kept changing while being checkpointed")
+}
+
+// merge brings a change made to the snippet outside the session into its code. Both that change and the edits
+// of the session are made against the code of the last checkpoint.
+func (s *session) merge(stored string, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	external, _, err := ot.Transform(ot.Diff(s.savedCode, stored), ot.Diff(s.savedCode, s.code))
+	if err != nil {
+		return err
+	}
+	if !external.IsNoop() {
+		code, err := external.Apply(s.code)
+		if err != nil {
+			return err
+		}
+		s.commitLocked(external, code, nil)
+	}
+	s.savedCode, s.version = stored, version
+	return nil
+}
+
+// closeLocked ends the session for everyone.
+func (s *session) closeLocked(reason string) {
+	s.closed = true
+	for conn := range s.conns {
+		conn.sendLocked(Message{Type: Closed, Error: reason})
+		conn.kickLocked()
+	}
+}
+
+// broadcastLocked sends a message to every participant but except.
+func (s *session) broadcastLocked(except *Conn, msg Message) {
+	for conn := range s.conns {
+		if conn != except {
+			conn.sendLocked(msg)
+		}
+	}
+}
+
+// sendLocked queues a message for the client, dropping the client if it is too far behind to take it.
+func (c *Conn) sendLocked(msg Message) {
+	if c.kicked {
+		return
+	}
+	// Checkpoints carry the revision that was saved, which edits made while saving may have moved past
+	if msg.Type != Checkpoint {
+		msg.Revision = c.session.revision
+	}
+	select {
+	case c.send <- msg:
+	default:
+		c.kickLocked()
+	}
+}
+
+// kickLocked closes the messages of the client, which disconnects it.
+func (c *Conn) kickLocked() {
+	if !c.kicked {
+		c.kicked = true
+		close(c.send)
+	}
+}
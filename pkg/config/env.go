@@ -1,13 +1,20 @@
 package config
 
 import (
-	"github.com/joho/godotenv"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	ServerPort string
 
+	// DBDriver is one of "postgres", "sqlite" or "memory"
+	DBDriver     string
+	DBPath       string
 	DBHost       string
 	DBPort       string
 	DBUser       string
@@ -18,6 +25,59 @@ type Config struct {
 	SMTPPassword string
 	SMTPHost     string
 	SMTPAddress  string
+
+	// TrustedProxies lists the addresses or CIDR ranges of the proxies in front of the server, whose
+	// X-Forwarded-For and X-Real-IP headers are believed. With none, the client IP is the address of the
+	// connection, since anyone can send those headers.
+	TrustedProxies []string
+
+	// MailDriver is how email is delivered: "smtp", "file" (a maildir at MailDir) or "memory"
+	MailDriver string
+	MailDir    string
+	// MailFrom is the sender of every email, and defaults to SMTPUsername
+	MailFrom string
+	// MailMaxAttempts is how many times the outbox tries to send an email before giving up on it
+	MailMaxAttempts int
+	// MailRetention is how long emails are kept in the outbox once they are sent or given up on. They hold
+	// activation and reset codes, so not for long.
+	MailRetention time.Duration
+
+	// WebhookMaxAttempts is how many times a webhook delivery is tried before giving up on it
+	WebhookMaxAttempts int
+	// WebhookDisableAfter is how many failed attempts in a row, across deliveries, disable a webhook
+	WebhookDisableAfter int
+	// WebhookTimeout is how long a webhook has to respond to a delivery
+	WebhookTimeout time.Duration
+
+	// CollabCheckpointInterval is how often collaborative editing sessions save the code of their snippet
+	CollabCheckpointInterval time.Duration
+
+	// SnippetMaxBytes is the largest code a snippet may have, and MaxRequestBytes the largest body of any request
+	SnippetMaxBytes int
+	MaxRequestBytes int
+	// QuotaMaxSnippets and QuotaMaxBytes limit how many snippets a user may have, and how much code in total.
+	// Zero disables a limit.
+	QuotaMaxSnippets int
+	QuotaMaxBytes    int
+
+	// AccountDeletionGracePeriod is how long a deleted account can still be restored
+	AccountDeletionGracePeriod time.Duration
+
+	// LockoutStore is where failed sign-in attempts are tracked, either "database" or "memory"
+	LockoutStore string
+
+	// RateLimitStore is where rate limit buckets are kept, either "memory" or "database"
+	RateLimitStore string
+	// Rate limits of the route groups, written as "requests/period" (see ratelimit.ParseLimit); empty disables them
+	RateLimitAuth          string
+	RateLimitSnippets      string
+	RateLimitSnippetWrites string
+
+	PasswordMinLength      int
+	PasswordMinCharClasses int
+	// BreachedPasswordsPath is a directory of leaked password hashes, or a file of them sorted by hash, see
+	// password.BreachedList
+	BreachedPasswordsPath string
 }
 
 var ENV = initConfig()
@@ -28,6 +88,8 @@ func initConfig() Config {
 
 	return Config{
 		ServerPort:   getEnv("SERVER_PORT", ":4000"),
+		DBDriver:     getEnv("DB_DRIVER", "postgres"),
+		DBPath:       getEnv("DB_PATH", "go-snip.db"),
 		DBPort:       getEnv("DB_PORT", "5432"),
 		DBHost:       getEnv("DB_HOST", "localhost"),
 		DBUser:       getEnv("DB_USER", "postgres"),
@@ -38,6 +100,37 @@ func initConfig() Config {
 		SMTPPassword: getEnv("SMTP_PASSWORD", "somePassword"),
 		SMTPHost:     getEnv("SMTP_HOST", "smtp.emailprovider.com"),
 		SMTPAddress:  getEnv("SMTP_ADDR", "smtp.emailprovider.com:someNumber"),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES"),
+
+		MailDriver:      getEnv("MAIL_DRIVER", "smtp"),
+		MailDir:         getEnv("MAIL_DIR", "mail"),
+		MailFrom:        getEnv("MAIL_FROM", getEnv("SMTP_USERNAME", "someEmail")),
+		MailMaxAttempts: getEnvInt("MAIL_MAX_ATTEMPTS", 8),
+		MailRetention:   getEnvDuration("MAIL_RETENTION", 24*time.Hour),
+
+		WebhookMaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookDisableAfter: getEnvInt("WEBHOOK_DISABLE_AFTER", 20),
+		WebhookTimeout:      getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+
+		CollabCheckpointInterval: getEnvDuration("COLLAB_CHECKPOINT_INTERVAL", 30*time.Second),
+
+		SnippetMaxBytes:  getEnvInt("SNIPPET_MAX_BYTES", 100<<10),
+		MaxRequestBytes:  getEnvInt("MAX_REQUEST_BYTES", 16<<20),
+		QuotaMaxSnippets: getEnvInt("QUOTA_MAX_SNIPPETS", 1000),
+		QuotaMaxBytes:    getEnvInt("QUOTA_MAX_BYTES", 10<<20),
+
+		AccountDeletionGracePeriod: getEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 14*24*time.Hour),
+		LockoutStore:               getEnv("LOCKOUT_STORE", "database"),
+
+		RateLimitStore:         getEnv("RATE_LIMIT_STORE", "memory"),
+		RateLimitAuth:          getEnv("RATE_LIMIT_AUTH", "20/1m"),
+		RateLimitSnippets:      getEnv("RATE_LIMIT_SNIPPETS", "300/1m"),
+		RateLimitSnippetWrites: getEnv("RATE_LIMIT_SNIPPET_WRITES", "60/1m"),
+
+		PasswordMinLength:      getEnvInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordMinCharClasses: getEnvInt("PASSWORD_MIN_CHAR_CLASSES", 2),
+		BreachedPasswordsPath:  getEnv("BREACHED_PASSWORDS_PATH", ""),
 	}
 }
 
@@ -47,3 +140,32 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvList splits a comma-separated variable, ignoring blank items.
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
@@ -1,61 +1,126 @@
+// Package email sends the emails of the API. Controllers queue emails in the outbox table with Enqueue, and
+// the Outbox worker delivers them in the background through a Mailer, retrying failures with backoff, so that
+// a slow or broken mail server never holds up or breaks a request. Emails are rendered from templates embedded
+// in the binary, in the language of their recipient.
 package email
 
 import (
 	"bytes"
 	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
 	"github.com/topboyasante/go-snip/pkg/config"
-	"html/template"
-	"log"
-	"net/smtp"
 )
 
-type SMTPAuth struct {
-	identity string
-	username string
-	password string
-	host     string
+// Message is an email ready to be delivered. It is sent as multipart/alternative, with a plain text and an
+// HTML version of the same content.
+type Message struct {
+	// ID is unique to the message, and is used in its Message-ID header
+	ID      string
+	Date    time.Time
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
 }
 
-var EmailConfig = initSMTPAuth()
-
-func initSMTPAuth() *SMTPAuth {
-	auth := &SMTPAuth{
-		identity: "",
-		username: config.ENV.SMTPUsername,
-		password: config.ENV.SMTPPassword,
-		host:     config.ENV.SMTPHost,
+// Bytes formats the message for delivery.
+func (msg Message) Bytes() []byte {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		w, _ := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(part.content))
+		qp.Close()
 	}
+	parts.Close()
 
-	return auth
-}
+	date := msg.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	id := msg.ID
+	if id == "" {
+		id = uuid.NewString()
+	}
+	domain := "go-snip"
+	if at := strings.LastIndex(msg.From, "@"); at >= 0 {
+		domain = strings.TrimRight(msg.From[at+1:], ">")
+	}
 
-func SendMailWithSMTP(authVar *SMTPAuth, subject, templatePath string, values any, to []string) {
-	var body bytes.Buffer
-	t, err := template.ParseFiles(templatePath)
-	if err != nil {
-		log.Fatal("error: unable to parse template")
+	var b bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", key, value)
 	}
-	t.Execute(&body, values)
+	header("From", msg.From)
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+id+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": parts.Boundary()}))
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return b.Bytes()
+}
 
-	auth := smtp.PlainAuth(
-		authVar.identity,
-		authVar.username,
-		authVar.password,
-		authVar.host,
-	)
+// Mailer delivers messages. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(msg Message) error
+}
 
-	headers := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";"
-	msg := fmt.Sprintf("Subject: %v\n%v \n\n%v", subject, headers, body.String())
+// DefaultMailer is the mailer selected by config.ENV.MailDriver.
+var DefaultMailer = initMailer()
 
-	err = smtp.SendMail(
-		config.ENV.SMTPAddress,
-		auth,
-		config.ENV.SMTPUsername,
-		to,
-		[]byte(msg),
-	)
+func initMailer() Mailer {
+	switch config.ENV.MailDriver {
+	case "file":
+		return NewFileMailer(config.ENV.MailDir)
+	case "memory":
+		return NewMemoryMailer()
+	default:
+		return NewSMTPMailer(config.ENV.SMTPAddress, config.ENV.SMTPHost, config.ENV.SMTPUsername, config.ENV.SMTPPassword)
+	}
+}
 
+// Enqueue renders an email in a locale, which should come from Locale, and queues it in the outbox for the
+// Outbox worker to send. It only fails if the email cannot be rendered or stored.
+func Enqueue(name, locale string, values any, to ...string) error {
+	subject, html, text, err := Render(name, locale, values)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
+
+	message := models.OutboxMessage{
+		ID:            uuid.New(),
+		Recipients:    to,
+		Subject:       subject,
+		HTML:          html,
+		Text:          text,
+		NextAttemptAt: time.Now(),
+	}
+	if err := message.Create(); err != nil {
+		return fmt.Errorf("queueing %s email: %w", name, err)
+	}
+
+	Outbox.Wake()
+	return nil
 }
@@ -0,0 +1,138 @@
+package email
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// smtpTimeout bounds a whole send to an SMTP server, from connecting to the end of the message, so that a server
+// that stops answering cannot hold up the outbox.
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer delivers messages to an SMTP server, authenticating with PLAIN auth.
+type SMTPMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer returns a mailer for the server at addr (host:port). host is the name the server's certificate
+// is checked against.
+func NewSMTPMailer(addr, host, username, password string) *SMTPMailer {
+	return &SMTPMailer{addr: addr, host: host, auth: smtp.PlainAuth("", username, password, host)}
+}
+
+// Send goes through the same steps as smtp.SendMail, which has no timeouts, on a connection with a deadline.
+func (m *SMTPMailer) Send(msg Message) error {
+	conn, err := net.DialTimeout("tcp", m.addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok {
+		if err := client.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(msg.From); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// FileMailer delivers messages to a maildir, where any mail client can read them. It is meant for development,
+// in place of a real mail server.
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) *FileMailer {
+	return &FileMailer{dir: dir}
+}
+
+// Send writes the message to the tmp directory of the maildir and then moves it to new, so that readers never
+// see a partial message.
+func (m *FileMailer) Send(msg Message) error {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(m.dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	name := fmt.Sprintf("%d.%s.%s", time.Now().UnixNano(), hex.EncodeToString(suffix), hostname)
+
+	tmp := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmp, msg.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.dir, "new", name))
+}
+
+// MemoryMailer keeps messages in process memory instead of delivering them.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.messages)
+}
@@ -0,0 +1,162 @@
+package email
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/pkg/config"
+)
+
+// Worker sends the messages in the outbox table. Several instances may share the table: every message is
+// claimed before it is sent, so that only one of them sends it.
+type Worker struct {
+	Mailer Mailer
+	From   string
+	// Interval is how often the outbox is checked for messages that are due, besides when Wake is called
+	Interval time.Duration
+	// BatchSize is how many messages are claimed at a time
+	BatchSize int
+	// Lease is how long a claimed message is left alone by other workers, and must be longer than a send takes
+	Lease time.Duration
+	// MaxAttempts is how many failures it takes to give up on a message
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure; it doubles with every further failure, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	wake chan struct{}
+
+	mu     sync.Mutex
+	status WorkerStatus
+}
+
+// WorkerStatus is what a worker was last seen doing.
+type WorkerStatus struct {
+	Running bool
+	// LastRun is when the outbox was last checked, and LastError what went wrong then, if anything
+	LastRun   time.Time
+	LastError string
+}
+
+// Outbox sends the messages queued by Enqueue through DefaultMailer, once it is started with Run.
+var Outbox = &Worker{
+	Mailer:      DefaultMailer,
+	From:        config.ENV.MailFrom,
+	Interval:    30 * time.Second,
+	BatchSize:   20,
+	Lease:       5 * time.Minute,
+	MaxAttempts: config.ENV.MailMaxAttempts,
+	BaseDelay:   30 * time.Second,
+	MaxDelay:    6 * time.Hour,
+	wake:        make(chan struct{}, 1),
+}
+
+// Wake makes a running worker check the outbox now rather than at its next interval.
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns what the worker was last seen doing.
+func (w *Worker) Status() WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Run sends messages until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	w.setStatus(func(status *WorkerStatus) { status.Running = true })
+	defer w.setStatus(func(status *WorkerStatus) { status.Running = false })
+
+	for {
+		// Keep going while whole batches are claimed, as there may be more messages due
+		for {
+			now := time.Now()
+			sent, err := w.Process(now)
+			if err != nil {
+				log.Println("failed to process email outbox:", err)
+			}
+			w.setStatus(func(status *WorkerStatus) {
+				status.LastRun, status.LastError = now, ""
+				if err != nil {
+					status.LastError = err.Error()
+				}
+			})
+			if err != nil || sent < w.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+func (w *Worker) setStatus(update func(*WorkerStatus)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	update(&w.status)
+}
+
+// Process claims a batch of due messages and tries to send each one, returning how many were claimed.
+func (w *Worker) Process(now time.Time) (int, error) {
+	messages, err := models.ClaimDueOutboxMessages(now, w.BatchSize, w.Lease)
+	for i := range messages {
+		w.send(&messages[i])
+	}
+	return len(messages), err
+}
+
+// send delivers a claimed message, and records the outcome.
+func (w *Worker) send(message *models.OutboxMessage) {
+	err := w.Mailer.Send(Message{
+		ID:      message.ID.String(),
+		Date:    message.CreatedAt,
+		From:    w.From,
+		To:      message.Recipients,
+		Subject: message.Subject,
+		Text:    message.Text,
+		HTML:    message.HTML,
+	})
+
+	now := time.Now()
+	if err == nil {
+		message.SentAt = &now
+		message.LastError = ""
+	} else {
+		message.Attempts++
+		message.LastError = err.Error()
+		message.NextAttemptAt = now.Add(w.backoff(message.Attempts))
+		if message.Attempts >= w.MaxAttempts {
+			message.FailedAt = &now
+			log.Printf("giving up on email %s after %d attempts: %v", message.ID, message.Attempts, err)
+		}
+	}
+
+	if err := message.Save(); err != nil {
+		// The message is sent again once its lease runs out
+		log.Printf("failed to update email %s in the outbox: %v", message.ID, err)
+	}
+}
+
+// backoff is the wait before the next attempt after the given number of failures.
+func (w *Worker) backoff(failures int) time.Duration {
+	delay := w.BaseDelay
+	for i := 1; i < failures && delay < w.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, w.MaxDelay)
+}
@@ -0,0 +1,167 @@
+package email
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"golang.org/x/text/language"
+)
+
+// Names of the emails, which are the names of their templates
+const (
+	ActivateAccount = "activate-account"
+	ResetPassword   = "reset-password"
+	ChangeEmail     = "change-email"
+	AccountLocked   = "account-locked"
+)
+
+// DefaultLocale is used for users whose language has no templates.
+const DefaultLocale = "en"
+
+// Every email has a template per locale, in a directory named after the locale: NAME.html holds the "title"
+// and "content" of the HTML part, which templates/layout.html wraps, and NAME.txt holds the text part and
+// defines the "subject". The common templates of a locale define what every email shares, such as the sign-off.
+//
+//go:embed templates
+var files embed.FS
+
+var names = []string{ActivateAccount, ResetPassword, ChangeEmail, AccountLocked}
+
+type localized struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+var (
+	// parsed maps a locale and the name of an email to its templates
+	parsed  map[string]map[string]localized
+	locales []string
+	matcher language.Matcher
+
+	parseOnce sync.Once
+	parseErr  error
+)
+
+// parse reads the embedded templates. They are part of the binary, so failing to parse them is a bug, which is
+// reported by every call to Render rather than by crashing the server.
+func parse() error {
+	parseOnce.Do(func() {
+		entries, err := fs.ReadDir(files, "templates")
+		if err != nil {
+			parseErr = err
+			return
+		}
+
+		parsed = make(map[string]map[string]localized)
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			locale := entry.Name()
+			parsed[locale] = make(map[string]localized, len(names))
+			for _, name := range names {
+				t, err := parseLocalized(locale, name)
+				if err != nil {
+					parseErr = fmt.Errorf("parsing %s email template for %s: %w", name, locale, err)
+					return
+				}
+				parsed[locale][name] = t
+			}
+			locales = append(locales, locale)
+		}
+
+		// The first supported tag is what the matcher falls back to
+		slices.SortFunc(locales, func(a, b string) int {
+			switch {
+			case a == DefaultLocale:
+				return -1
+			case b == DefaultLocale:
+				return 1
+			}
+			return strings.Compare(a, b)
+		})
+		tags := make([]language.Tag, 0, len(locales))
+		for _, locale := range locales {
+			tags = append(tags, language.Make(locale))
+		}
+		matcher = language.NewMatcher(tags)
+	})
+	return parseErr
+}
+
+func parseLocalized(locale, name string) (localized, error) {
+	dir := "templates/" + locale + "/"
+
+	html, err := htmltemplate.ParseFS(files, "templates/layout.html", dir+"common.html", dir+name+".html")
+	if err != nil {
+		return localized{}, err
+	}
+	if _, err := html.New("lang").Parse(locale); err != nil {
+		return localized{}, err
+	}
+
+	text, err := texttemplate.ParseFS(files, dir+"common.txt", dir+name+".txt")
+	if err != nil {
+		return localized{}, err
+	}
+	return localized{html: html.Lookup("layout.html"), text: text.Lookup(name + ".txt")}, nil
+}
+
+// Locale picks the locale with templates that best matches the preferences, which are language tags such as
+// "fr-CA" or Accept-Language headers, most important first.
+func Locale(preferences ...string) string {
+	if parse() != nil {
+		return DefaultLocale
+	}
+
+	var tags []language.Tag
+	for _, preference := range preferences {
+		parsed, _, err := language.ParseAcceptLanguage(preference)
+		if err == nil {
+			tags = append(tags, parsed...)
+		}
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return locales[index]
+}
+
+// Render renders an email in a locale, which should come from Locale.
+func Render(name, locale string, values any) (subject, html, text string, err error) {
+	if err := parse(); err != nil {
+		return "", "", "", err
+	}
+	t, ok := parsed[locale][name]
+	if !ok {
+		if t, ok = parsed[DefaultLocale][name]; !ok {
+			return "", "", "", fmt.Errorf("no email template named %q", name)
+		}
+	}
+
+	var b strings.Builder
+	if err := t.text.ExecuteTemplate(&b, "subject", values); err != nil {
+		return "", "", "", fmt.Errorf("rendering subject of %s email: %w", name, err)
+	}
+	subject = strings.TrimSpace(b.String())
+
+	b.Reset()
+	if err := t.text.Execute(&b, values); err != nil {
+		return "", "", "", fmt.Errorf("rendering text of %s email: %w", name, err)
+	}
+	text = strings.TrimSpace(b.String()) + "\n"
+
+	b.Reset()
+	if err := t.html.Execute(&b, values); err != nil {
+		return "", "", "", fmt.Errorf("rendering HTML of %s email: %w", name, err)
+	}
+	html = b.String()
+	return subject, html, text, nil
+}
@@ -0,0 +1,145 @@
+// Package events is the in-process bus that the snippet controllers publish changes on, for the stream routes to
+// send to their subscribers. Events only live in memory: a subscriber that falls behind or reconnects after the
+// bus has moved past it is told to start over rather than being sent a partial history.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of the events on Snippets, which are also the events webhooks subscribe to
+const (
+	SnippetCreated = "snippet.created"
+	SnippetUpdated = "snippet.updated"
+	SnippetDeleted = "snippet.deleted"
+)
+
+// Event is a change to a snippet.
+type Event struct {
+	// ID orders the events of a bus. IDs start from the time the bus was created, so that those of an earlier
+	// process are older than any event of the current one.
+	ID        uint64
+	Type      string
+	CreatedAt time.Time
+	OwnerID   uuid.UUID
+	SnippetID uuid.UUID
+	Tags      []string
+	// Public is whether anyone could see the snippet when the event happened. Otherwise only its owner and
+	// admins may see the event.
+	Public bool
+	Data   any
+}
+
+// Snippets carries the changes to snippets.
+var Snippets = NewBus(1000, 64)
+
+// Bus fans events out to subscriptions, and keeps the latest ones so that subscribers can catch up on what they
+// missed while reconnecting.
+type Bus struct {
+	mu      sync.Mutex
+	startID uint64
+	lastID  uint64
+	// history holds the latest events, oldest first
+	history     []Event
+	historySize int
+	bufferSize  int
+	subs        map[*Subscription]struct{}
+}
+
+// NewBus returns a bus that remembers historySize events, and buffers bufferSize events for every subscription.
+func NewBus(historySize, bufferSize int) *Bus {
+	start := uint64(time.Now().UnixMicro())
+	return &Bus{
+		startID:     start,
+		lastID:      start,
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subs:        make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events of a bus that match its filter, in order, on C. C is closed when the
+// subscription is cancelled, or when it falls so far behind that its buffer fills up; the subscriber can then
+// resume from the last event it received.
+type Subscription struct {
+	C <-chan Event
+	// From is the ID of the last event published before the subscription started
+	From uint64
+
+	c     chan Event
+	match func(Event) bool
+	bus   *Bus
+}
+
+// Publish assigns the event an ID and a time, and sends it to every matching subscription. It never blocks.
+func (b *Bus) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	e.CreatedAt = time.Now().UTC()
+
+	if len(b.history) == b.historySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, e)
+
+	for sub := range b.subs {
+		if !sub.match(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			b.remove(sub)
+		}
+	}
+	return e
+}
+
+// Subscribe starts a subscription to the events that match. If after is not zero, the subscription resumes
+// after the event with that ID: the matching events since then are returned, and complete reports whether the
+// bus still remembered all of them. Nothing is published between the returned events and the first one on C.
+func (b *Bus) Subscribe(match func(Event) bool, after uint64) (sub *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Event, b.bufferSize)
+	sub = &Subscription{C: c, From: b.lastID, c: c, match: match, bus: b}
+	b.subs[sub] = struct{}{}
+
+	if after == 0 {
+		return sub, nil, true
+	}
+
+	oldest := b.lastID + 1
+	if len(b.history) > 0 {
+		oldest = b.history[0].ID
+	}
+	complete = after >= b.startID && after <= b.lastID && after+1 >= oldest
+	for _, e := range b.history {
+		if e.ID > after && match(e) {
+			missed = append(missed, e)
+		}
+	}
+	return sub, missed, complete
+}
+
+// Cancel stops the subscription and closes C. It may be called more than once.
+func (sub *Subscription) Cancel() {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+	sub.bus.remove(sub)
+}
+
+// remove must be called with the lock held.
+func (b *Bus) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
@@ -0,0 +1,130 @@
+// Package exporter writes snippets in the formats of editors and text expanders, translating the tab stops of
+// their code (see package tabstop) into the syntax of each one. Every format is registered under the name
+// clients select it with.
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/topboyasante/go-snip/api/v1/models"
+)
+
+// Format writes a set of snippets as a single file, which may be an archive for formats with a file per snippet.
+type Format struct {
+	ContentType string
+	// Extension is the extension of the written file, including the dot
+	Extension string
+	Write     func(w io.Writer, snippets []models.Snippet) error
+}
+
+var (
+	mu      sync.RWMutex
+	formats = map[string]Format{
+		"vscode":    {ContentType: "application/json", Extension: ".code-snippets", Write: WriteVSCode},
+		"sublime":   {ContentType: "application/zip", Extension: ".zip", Write: WriteSublime},
+		"ultisnips": {ContentType: "text/plain; charset=utf-8", Extension: ".snippets", Write: WriteUltiSnips},
+		"espanso":   {ContentType: "application/yaml", Extension: ".yml", Write: WriteEspanso},
+		"jetbrains": {ContentType: "application/xml", Extension: ".xml", Write: WriteJetBrains},
+	}
+)
+
+// Register adds a format, or replaces an existing one.
+func Register(name string, format Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	formats[name] = format
+}
+
+// Lookup returns a format by name.
+func Lookup(name string) (Format, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	format, ok := formats[name]
+	return format, ok
+}
+
+// Formats lists the registered formats in alphabetical order.
+func Formats() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Trigger is the word that expands a snippet: its own trigger, or a slug of its title.
+func Trigger(snippet models.Snippet) string {
+	if snippet.Trigger != "" {
+		return snippet.Trigger
+	}
+
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(snippet.Title) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 32 {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "snippet"
+	}
+	return b.String()
+}
+
+// uniqueNames returns a name per snippet, suffixed with a number when it is already taken.
+func uniqueNames(snippets []models.Snippet, name func(models.Snippet) string) []string {
+	names := make([]string, len(snippets))
+	taken := make(map[string]bool, len(snippets))
+	for i, snippet := range snippets {
+		base := name(snippet)
+		names[i] = base
+		for n := 2; taken[names[i]]; n++ {
+			names[i] = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[names[i]] = true
+	}
+	return names
+}
+
+// languages maps the tags that name a language to the language identifiers of VS Code and the scopes of
+// Sublime Text. Other tags are not languages, and do not restrict where a snippet is offered.
+var languages = map[string]struct{ vscode, sublime string }{
+	"c":          {"c", "source.c"},
+	"cpp":        {"cpp", "source.c++"},
+	"csharp":     {"csharp", "source.cs"},
+	"css":        {"css", "source.css"},
+	"go":         {"go", "source.go"},
+	"html":       {"html", "text.html"},
+	"java":       {"java", "source.java"},
+	"javascript": {"javascript", "source.js"},
+	"json":       {"json", "source.json"},
+	"kotlin":     {"kotlin", "source.kotlin"},
+	"lua":        {"lua", "source.lua"},
+	"markdown":   {"markdown", "text.html.markdown"},
+	"php":        {"php", "source.php"},
+	"python":     {"python", "source.python"},
+	"ruby":       {"ruby", "source.ruby"},
+	"rust":       {"rust", "source.rust"},
+	"shell":      {"shellscript", "source.shell"},
+	"sql":        {"sql", "source.sql"},
+	"swift":      {"swift", "source.swift"},
+	"typescript": {"typescript", "source.ts"},
+	"yaml":       {"yaml", "source.yaml"},
+}
@@ -0,0 +1,270 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/pkg/tabstop"
+)
+
+const header = "Exported from go-snip"
+
+// textMate writes code in the TextMate syntax shared by VS Code, Sublime Text and UltiSnips, escaping the
+// characters in special with a backslash.
+func textMate(code, special string) string {
+	escape := func(text, chars string) string {
+		var b strings.Builder
+		for _, r := range text {
+			if strings.ContainsRune(chars, r) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+
+	var b strings.Builder
+	for _, segment := range tabstop.Parse(code) {
+		switch {
+		case !segment.IsStop():
+			b.WriteString(escape(segment.Text, special))
+		case segment.Text == "":
+			fmt.Fprintf(&b, "${%d}", segment.Stop)
+		default:
+			fmt.Fprintf(&b, "${%d:%s}", segment.Stop, escape(segment.Text, special+"}"))
+		}
+	}
+	return b.String()
+}
+
+// languageTags returns the language identifiers of the tags that name a language, for one of the editors in
+// the languages map.
+func languageTags(tags []string, editor func(l struct{ vscode, sublime string }) string) []string {
+	var ids []string
+	for _, tag := range tags {
+		if language, ok := languages[tag]; ok {
+			ids = append(ids, editor(language))
+		}
+	}
+	return ids
+}
+
+type vscodeSnippet struct {
+	Prefix      string   `json:"prefix"`
+	Body        []string `json:"body"`
+	Description string   `json:"description,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+}
+
+// WriteVSCode writes a VS Code .code-snippets file, keyed by the titles of the snippets. Snippets tagged with
+// languages are only offered in those languages.
+func WriteVSCode(w io.Writer, snippets []models.Snippet) error {
+	names := uniqueNames(snippets, func(s models.Snippet) string { return s.Title })
+	file := make(map[string]vscodeSnippet, len(snippets))
+	for i, snippet := range snippets {
+		file[names[i]] = vscodeSnippet{
+			Prefix:      Trigger(snippet),
+			Body:        strings.Split(textMate(snippet.Code, `$\`), "\n"),
+			Description: snippet.Description,
+			Scope: strings.Join(languageTags(snippet.Tags, func(l struct{ vscode, sublime string }) string {
+				return l.vscode
+			}), ","),
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(file)
+}
+
+// WriteSublime writes a zip archive with a .sublime-snippet file per snippet, named by its trigger.
+func WriteSublime(w io.Writer, snippets []models.Snippet) error {
+	zw := zip.NewWriter(w)
+	names := uniqueNames(snippets, Trigger)
+	for i, snippet := range snippets {
+		f, err := zw.Create(names[i] + ".sublime-snippet")
+		if err != nil {
+			return err
+		}
+
+		var b bytes.Buffer
+		b.WriteString("<!-- " + header + " -->\n<snippet>\n\t<content><![CDATA[\n")
+		// The content cannot end the CDATA section early
+		b.WriteString(strings.ReplaceAll(textMate(snippet.Code, `$\`), "]]>", "]]]]><![CDATA[>"))
+		b.WriteString("\n]]></content>\n\t<tabTrigger>")
+		xml.EscapeText(&b, []byte(Trigger(snippet)))
+		b.WriteString("</tabTrigger>\n\t<description>")
+		xml.EscapeText(&b, []byte(snippet.Title))
+		b.WriteString("</description>\n")
+		scopes := languageTags(snippet.Tags, func(l struct{ vscode, sublime string }) string { return l.sublime })
+		if len(scopes) > 0 {
+			b.WriteString("\t<scope>" + strings.Join(scopes, ", ") + "</scope>\n")
+		}
+		b.WriteString("</snippet>\n")
+
+		if _, err := f.Write(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// WriteUltiSnips writes a Vim UltiSnips .snippets file, described by the titles of the snippets.
+func WriteUltiSnips(w io.Writer, snippets []models.Snippet) error {
+	var b bytes.Buffer
+	b.WriteString("# " + header + "\n")
+	for _, snippet := range snippets {
+		title := strings.ReplaceAll(snippet.Title, `"`, `'`)
+		fmt.Fprintf(&b, "\nsnippet %s \"%s\"\n", Trigger(snippet), title)
+		b.WriteString(textMate(snippet.Code, "$`\\"))
+		b.WriteString("\nendsnippet\n")
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteEspanso writes an Espanso match file. Espanso has no tab stops, so they are replaced by their default
+// text, and the cursor is left at the final tab stop or else the first one. Triggers start with a colon, as is
+// the custom with Espanso.
+func WriteEspanso(w io.Writer, snippets []models.Snippet) error {
+	var b bytes.Buffer
+	b.WriteString("# " + header + "\n")
+	if len(snippets) == 0 {
+		b.WriteString("matches: []\n")
+	} else {
+		b.WriteString("matches:\n")
+	}
+	for _, snippet := range snippets {
+		segments := tabstop.Parse(snippet.Code)
+
+		cursor := -1
+		for _, segment := range segments {
+			if segment.IsStop() && (cursor == -1 || segment.Stop == 0 || cursor != 0 && segment.Stop < cursor) {
+				cursor = segment.Stop
+			}
+		}
+
+		var replace strings.Builder
+		for _, segment := range segments {
+			if segment.IsStop() && segment.Stop == cursor {
+				replace.WriteString("$|$")
+				cursor = -1
+			}
+			replace.WriteString(segment.Text)
+		}
+
+		fmt.Fprintf(&b, "  - trigger: %s\n", yamlString(":"+Trigger(snippet)))
+		fmt.Fprintf(&b, "    label: %s\n", yamlString(snippet.Title))
+		fmt.Fprintf(&b, "    replace: %s\n", yamlString(replace.String()))
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// yamlString quotes a string for YAML, which accepts JSON strings.
+func yamlString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+type jetbrainsTemplateSet struct {
+	XMLName   xml.Name            `xml:"templateSet"`
+	Group     string              `xml:"group,attr"`
+	Templates []jetbrainsTemplate `xml:"template"`
+}
+
+type jetbrainsTemplate struct {
+	Name             string              `xml:"name,attr"`
+	Value            string              `xml:"value,attr"`
+	Description      string              `xml:"description,attr"`
+	ToReformat       bool                `xml:"toReformat,attr"`
+	ToShortenFQNames bool                `xml:"toShortenFQNames,attr"`
+	Variables        []jetbrainsVariable `xml:"variable"`
+	Context          []jetbrainsOption   `xml:"context>option"`
+}
+
+type jetbrainsVariable struct {
+	Name         string `xml:"name,attr"`
+	Expression   string `xml:"expression,attr"`
+	DefaultValue string `xml:"defaultValue,attr"`
+	AlwaysStopAt bool   `xml:"alwaysStopAt,attr"`
+}
+
+type jetbrainsOption struct {
+	Name  string `xml:"name,attr"`
+	Value bool   `xml:"value,attr"`
+}
+
+// WriteJetBrains writes a JetBrains live template set, which can be copied into the templates directory of an
+// IDE's configuration. Tab stops become the variables $VAR1$, $VAR2$ and so on, and the final tab stop $END$.
+func WriteJetBrains(w io.Writer, snippets []models.Snippet) error {
+	set := jetbrainsTemplateSet{Group: "go-snip"}
+	names := uniqueNames(snippets, Trigger)
+	for i, snippet := range snippets {
+		template := jetbrainsTemplate{
+			Name:             names[i],
+			Description:      snippet.Description,
+			ToShortenFQNames: true,
+			Context:          []jetbrainsOption{{Name: "OTHER", Value: true}},
+		}
+		if template.Description == "" {
+			template.Description = snippet.Title
+		}
+
+		var value strings.Builder
+		defaults := make(map[int]string)
+		for _, segment := range tabstop.Parse(snippet.Code) {
+			switch {
+			case !segment.IsStop():
+				value.WriteString(strings.ReplaceAll(segment.Text, "$", "$$"))
+			case segment.Stop == 0:
+				value.WriteString("$END$")
+			default:
+				value.WriteString("$VAR" + strconv.Itoa(segment.Stop) + "$")
+				if _, seen := defaults[segment.Stop]; !seen || defaults[segment.Stop] == "" {
+					defaults[segment.Stop] = segment.Text
+				}
+			}
+		}
+		template.Value = value.String()
+
+		// Variables are visited in the order they are declared
+		stops := make([]int, 0, len(defaults))
+		for stop := range defaults {
+			stops = append(stops, stop)
+		}
+		slices.Sort(stops)
+		for _, stop := range stops {
+			variable := jetbrainsVariable{Name: "VAR" + strconv.Itoa(stop), AlwaysStopAt: true}
+			if text := defaults[stop]; text != "" {
+				variable.DefaultValue = strconv.Quote(text)
+			}
+			template.Variables = append(template.Variables, variable)
+		}
+
+		set.Templates = append(set.Templates, template)
+	}
+
+	if _, err := io.WriteString(w, "<!-- "+header+" -->\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,22 @@
+package importer
+
+import (
+	"errors"
+
+	"github.com/topboyasante/go-snip/pkg/tabstop"
+)
+
+// ParseFile imports a plain file, such as one from a directory of snippets or a Pastebin dump, as a single
+// snippet titled with its path and tagged with the language of its extension. Its code has no tab stops.
+func ParseFile(file File) ([]Item, error) {
+	item := Item{Source: file.Name}
+	if !isText(file.Data) {
+		item.Err = errors.New("is not a text file")
+		return []Item{item}, nil
+	}
+
+	item.Snippet.Title = file.Name
+	item.Snippet.Code = tabstop.Escape(string(file.Data))
+	item.Snippet.Tags = tags(language(file.Name))
+	return []Item{item}, nil
+}
@@ -0,0 +1,80 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/topboyasante/go-snip/pkg/tabstop"
+)
+
+// gist is the part of a gist, as returned by the GitHub API, that is imported.
+type gist struct {
+	ID          string              `json:"id"`
+	Description string              `json:"description"`
+	Files       map[string]gistFile `json:"files"`
+}
+
+type gistFile struct {
+	Filename  string  `json:"filename"`
+	Language  string  `json:"language"`
+	Content   *string `json:"content"`
+	Truncated bool    `json:"truncated"`
+}
+
+// ParseGist reads a gist, or an array of gists, in the JSON returned by GET /gists/{id} of the GitHub API.
+// Every file of a gist becomes a snippet titled with the file name, tagged with its language and described
+// with the description of the gist.
+func ParseGist(file File) ([]Item, error) {
+	var gists []gist
+	data := bytes.TrimSpace(file.Data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &gists); err != nil {
+			return nil, fmt.Errorf("not a list of gists: %w", err)
+		}
+	} else {
+		var g gist
+		if err := json.Unmarshal(data, &g); err != nil {
+			return nil, fmt.Errorf("not a gist: %w", err)
+		}
+		gists = append(gists, g)
+	}
+
+	var items []Item
+	for _, g := range gists {
+		if len(g.Files) == 0 {
+			return nil, fmt.Errorf("gist %q has no files; is this a gist from the GitHub API?", g.ID)
+		}
+
+		names := make([]string, 0, len(g.Files))
+		for name := range g.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			f := g.Files[name]
+			if f.Filename == "" {
+				f.Filename = name
+			}
+
+			item := Item{Source: fmt.Sprintf("%s: gist %s, %s", file.Name, g.ID, f.Filename)}
+			switch {
+			case f.Content == nil:
+				// Listings of gists leave the content out
+				item.Err = errors.New("has no content; export each gist with GET /gists/{id}")
+			case f.Truncated:
+				item.Err = errors.New("content was truncated by the GitHub API")
+			default:
+				item.Snippet.Title = f.Filename
+				item.Snippet.Description = g.Description
+				item.Snippet.Code = tabstop.Escape(*f.Content)
+				item.Snippet.Tags = tags(f.Language)
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
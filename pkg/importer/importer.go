@@ -0,0 +1,145 @@
+// Package importer turns the snippets of other tools into models.Snippet. Every format has a Parser, registered
+// under the name clients select it with; parsers only read files, and leave validation, conflicts and saving to
+// the caller.
+package importer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/topboyasante/go-snip/api/v1/models"
+)
+
+// File is one file to import, such as an upload or a file found by walking a directory.
+type File struct {
+	// Name is the path of the file, relative to the directory it came from, with forward slashes
+	Name string
+	Data []byte
+}
+
+// Item is a snippet found in a file.
+type Item struct {
+	// Source locates the snippet in the imported files, e.g. "gists.json: gist aa5a315d, main.go"
+	Source  string
+	Snippet models.Snippet
+	// Err is set when the entry was found but could not be turned into a snippet
+	Err error
+}
+
+// A Parser returns the snippets in a file. It only returns an error when the file as a whole cannot be read;
+// problems with single entries are reported in Item.Err.
+type Parser func(file File) ([]Item, error)
+
+var (
+	mu      sync.RWMutex
+	parsers = map[string]Parser{
+		"gist":      ParseGist,
+		"vscode":    ParseVSCode,
+		"jetbrains": ParseJetBrains,
+		"dir":       ParseFile,
+	}
+)
+
+// Register adds a format, or replaces the parser of an existing one.
+func Register(format string, parser Parser) {
+	mu.Lock()
+	defer mu.Unlock()
+	parsers[format] = parser
+}
+
+// Lookup returns the parser of a format.
+func Lookup(format string) (Parser, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	parser, ok := parsers[format]
+	return parser, ok
+}
+
+// Formats lists the registered formats in alphabetical order.
+func Formats() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	formats := make([]string, 0, len(parsers))
+	for format := range parsers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Parse reads every file with the parser of a format. A file that cannot be read is reported as a single
+// failed item, so that the other files are still imported.
+func Parse(format string, files []File) ([]Item, error) {
+	parser, ok := Lookup(format)
+	if !ok {
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+
+	var items []Item
+	for _, file := range files {
+		found, err := parser(file)
+		if err != nil {
+			items = append(items, Item{Source: file.Name, Err: err})
+			continue
+		}
+		items = append(items, found...)
+	}
+	return items, nil
+}
+
+// tag turns a language or group name into a tag, e.g. "Vim Script" into "vim-script".
+func tag(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer(" ", "-", "\t", "-", ",", "-").Replace(name)
+	if len(name) > 32 {
+		return ""
+	}
+	return name
+}
+
+// tags turns names into tags, dropping empty and repeated ones.
+func tags(names ...string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if t := tag(name); t != "" && !seen[t] {
+			seen[t] = true
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+var languages = map[string]string{
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp", ".cs": "csharp", ".css": "css",
+	".dart": "dart", ".ex": "elixir", ".exs": "elixir", ".go": "go", ".hs": "haskell", ".html": "html",
+	".java": "java", ".js": "javascript", ".jsx": "javascript", ".json": "json", ".kt": "kotlin", ".lua": "lua",
+	".md": "markdown", ".php": "php", ".pl": "perl", ".ps1": "powershell", ".py": "python", ".r": "r",
+	".rb": "ruby", ".rs": "rust", ".scala": "scala", ".sh": "shell", ".bash": "shell", ".zsh": "shell",
+	".sql": "sql", ".swift": "swift", ".tf": "terraform", ".toml": "toml", ".ts": "typescript",
+	".tsx": "typescript", ".vim": "vim", ".xml": "xml", ".yaml": "yaml", ".yml": "yaml",
+}
+
+// language guesses the language of a file from its extension, or returns "" if it does not know it.
+func language(name string) string {
+	return languages[strings.ToLower(path.Ext(name))]
+}
+
+// trigger returns name as the trigger of a snippet, or "" if it cannot be one.
+func trigger(name string) string {
+	if len(name) > 64 || strings.ContainsFunc(name, unicode.IsSpace) {
+		return ""
+	}
+	return name
+}
+
+// isText reports whether data looks like text rather than a binary file.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !strings.ContainsRune(string(data), 0)
+}
@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/topboyasante/go-snip/pkg/tabstop"
+)
+
+// jetbrainsTemplateSet is a file of live templates, as found in the templates directory of an IDE's
+// configuration or in an exported settings archive.
+type jetbrainsTemplateSet struct {
+	Group     string              `xml:"group,attr"`
+	Templates []jetbrainsTemplate `xml:"template"`
+}
+
+type jetbrainsTemplate struct {
+	Name        string              `xml:"name,attr"`
+	Value       string              `xml:"value,attr"`
+	Description string              `xml:"description,attr"`
+	Variables   []jetbrainsVariable `xml:"variable"`
+	Context     []jetbrainsOption   `xml:"context>option"`
+}
+
+type jetbrainsVariable struct {
+	Name         string `xml:"name,attr"`
+	DefaultValue string `xml:"defaultValue,attr"`
+}
+
+type jetbrainsOption struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// ParseJetBrains reads a JetBrains live template set. Every template becomes a snippet titled with its
+// abbreviation, triggered by it and tagged with its group and the contexts it is enabled in.
+func ParseJetBrains(file File) ([]Item, error) {
+	var set jetbrainsTemplateSet
+	if err := xml.Unmarshal(file.Data, &set); err != nil {
+		return nil, fmt.Errorf("not a JetBrains template set: %w", err)
+	}
+	if len(set.Templates) == 0 {
+		return nil, errors.New("has no live templates")
+	}
+
+	items := make([]Item, 0, len(set.Templates))
+	for _, t := range set.Templates {
+		item := Item{Source: fmt.Sprintf("%s: %s", file.Name, t.Name)}
+		if t.Name == "" {
+			item.Err = errors.New("template has no abbreviation")
+			items = append(items, item)
+			continue
+		}
+
+		names := []string{set.Group}
+		for _, option := range t.Context {
+			if option.Value == "true" {
+				names = append(names, option.Name)
+			}
+		}
+
+		item.Snippet.Title = t.Name
+		item.Snippet.Description = t.Description
+		item.Snippet.Trigger = trigger(t.Name)
+		item.Snippet.Code = jetbrainsCode(t)
+		item.Snippet.Tags = tags(names...)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// jetbrainsCode turns the variables of a template into tab stops, numbered in the order they first appear.
+// $END$ is the final tab stop, and a variable whose default value is a string literal is filled with it.
+func jetbrainsCode(t jetbrainsTemplate) string {
+	defaults := make(map[string]string)
+	for _, v := range t.Variables {
+		if text, err := strconv.Unquote(v.DefaultValue); err == nil {
+			defaults[v.Name] = text
+		}
+	}
+
+	var segments []tabstop.Segment
+	stops := make(map[string]int)
+	text := func(s string) {
+		if n := len(segments); n > 0 && !segments[n-1].IsStop() {
+			segments[n-1].Text += s
+			return
+		}
+		segments = append(segments, tabstop.Segment{Stop: -1, Text: s})
+	}
+
+	value := t.Value
+	for {
+		start := strings.IndexByte(value, '$')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(value[start+1:], '$')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+
+		text(value[:start])
+		switch name := value[start+1 : end]; {
+		case name == "":
+			text("$")
+		case name == "END":
+			segments = append(segments, tabstop.Segment{Stop: 0})
+		default:
+			stop, ok := stops[name]
+			if !ok {
+				stop = len(stops) + 1
+				stops[name] = stop
+			}
+			segments = append(segments, tabstop.Segment{Stop: stop, Text: defaults[name]})
+		}
+		value = value[end+1:]
+	}
+	text(value)
+	return tabstop.Format(segments)
+}
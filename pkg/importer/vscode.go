@@ -0,0 +1,131 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// vscodeSnippet is an entry of a VS Code snippets file. Prefix and body may be a string or a list of strings.
+type vscodeSnippet struct {
+	Prefix      json.RawMessage `json:"prefix"`
+	Body        json.RawMessage `json:"body"`
+	Description string          `json:"description"`
+	Scope       string          `json:"scope"`
+}
+
+// ParseVSCode reads a VS Code snippets file: a global .code-snippets file, whose entries name their languages
+// in scope, or a language file like go.json, whose entries are all for that language. Every entry becomes a
+// snippet titled with its name, triggered by its first prefix and tagged with its languages. Bodies already use
+// the tab stop syntax of snippets. Comments and trailing commas, which VS Code
+// allows, are accepted.
+func ParseVSCode(file File) ([]Item, error) {
+	var entries map[string]vscodeSnippet
+	if err := json.Unmarshal(stripJSONC(file.Data), &entries); err != nil {
+		return nil, fmt.Errorf("not a VS Code snippets file: %w", err)
+	}
+
+	// The user snippets of a language are in a file named after it
+	var fileLanguage string
+	if path.Ext(file.Name) == ".json" {
+		fileLanguage = strings.TrimSuffix(path.Base(file.Name), ".json")
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make([]Item, 0, len(names))
+	for _, name := range names {
+		entry := entries[name]
+		item := Item{Source: fmt.Sprintf("%s: %s", file.Name, name)}
+
+		body, err := stringOrLines(entry.Body)
+		prefix, _ := stringOrLines(entry.Prefix)
+		if err != nil {
+			item.Err = fmt.Errorf("body: %w", err)
+		} else {
+			item.Snippet.Trigger, _, _ = strings.Cut(prefix, "\n")
+			item.Snippet.Trigger = trigger(item.Snippet.Trigger)
+			item.Snippet.Title = name
+			item.Snippet.Description = entry.Description
+			item.Snippet.Code = body
+			if entry.Scope != "" {
+				item.Snippet.Tags = tags(strings.Split(entry.Scope, ",")...)
+			} else {
+				item.Snippet.Tags = tags(fileLanguage)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// stringOrLines decodes a string, or a list of lines joined with newlines.
+func stringOrLines(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", errors.New("is missing")
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err != nil {
+		return "", errors.New("must be a string or a list of strings")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// stripJSONC turns JSON with comments and trailing commas into plain JSON.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+
+		if inString {
+			out = append(out, ch)
+			if ch == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if ch == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case ch == '"':
+			inString = true
+			out = append(out, ch)
+		case ch == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case ch == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case ch == '}' || ch == ']':
+			// Drop a trailing comma, along with the whitespace after it
+			trimmed := strings.TrimRight(string(out), " \t\r\n")
+			if strings.HasSuffix(trimmed, ",") {
+				out = append(out[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+			out = append(out, ch)
+		default:
+			out = append(out, ch)
+		}
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package lockout
+
+import (
+	"errors"
+
+	"github.com/topboyasante/go-snip/api/v1/models"
+)
+
+// maxUpdateAttempts is how many times DatabaseStore.Update reads an attempt again after losing a race for it.
+const maxUpdateAttempts = 5
+
+// DatabaseStore keeps attempts in the login_attempts table, so they survive restarts and are shared between instances.
+type DatabaseStore struct{}
+
+func NewDatabaseStore() *DatabaseStore {
+	return &DatabaseStore{}
+}
+
+func (s *DatabaseStore) Get(key string) (Attempt, error) {
+	row, _, err := models.GetLoginAttempt(key)
+	if err != nil {
+		return Attempt{}, err
+	}
+	return toAttempt(row), nil
+}
+
+func (s *DatabaseStore) Update(key string, fn func(Attempt) Attempt) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		row, found, err := models.GetLoginAttempt(key)
+		if err != nil {
+			return err
+		}
+
+		a := fn(toAttempt(row))
+		updated := models.LoginAttempt{
+			Key:           key,
+			Failures:      a.Failures,
+			LastFailureAt: a.LastFailure,
+			LockedUntil:   a.LockedUntil,
+			Version:       row.Version + 1,
+		}
+		var saved bool
+		if found {
+			saved, err = updated.SaveIfVersion(row.Version)
+		} else {
+			saved, err = updated.Create()
+		}
+		if err != nil || saved {
+			return err
+		}
+	}
+	return errors.New("too many concurrent attempts for the same key")
+}
+
+func (s *DatabaseStore) Delete(key string) error {
+	return models.DeleteLoginAttempt(key)
+}
+
+func toAttempt(row models.LoginAttempt) Attempt {
+	return Attempt{
+		Failures:    row.Failures,
+		LastFailure: row.LastFailureAt,
+		LockedUntil: row.LockedUntil,
+	}
+}
@@ -0,0 +1,142 @@
+package lockout
+
+import (
+	"math"
+	"time"
+
+	"github.com/topboyasante/go-snip/pkg/config"
+)
+
+// Attempt tracks the failed attempts made against a single key, such as an account or an IP address.
+type Attempt struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store persists attempts. Implementations must be safe for concurrent use.
+type Store interface {
+	Get(key string) (Attempt, error)
+	// Update replaces the attempt for key with what fn returns for it, atomically, so that concurrent failures
+	// are all counted. fn may be called more than once.
+	Update(key string, fn func(Attempt) Attempt) error
+	Delete(key string) error
+}
+
+// Policy describes how quickly a key is slowed down and locked out.
+type Policy struct {
+	// FreeAttempts is the number of failures allowed before any delay is enforced
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure past FreeAttempts; it doubles with every further failure
+	BaseDelay time.Duration
+	// MaxFailures is the number of failures that triggers a lockout
+	MaxFailures int
+	// LockoutDuration is how long a key stays locked out
+	LockoutDuration time.Duration
+	// Window is how long failures are remembered for after the last one
+	Window time.Duration
+}
+
+type Guard struct {
+	Store  Store
+	Policy Policy
+}
+
+var (
+	// Accounts slows down and locks out attempts against a single account
+	Accounts = &Guard{
+		Store: defaultStore,
+		Policy: Policy{
+			FreeAttempts:    3,
+			BaseDelay:       time.Second,
+			MaxFailures:     10,
+			LockoutDuration: 15 * time.Minute,
+			Window:          time.Hour,
+		},
+	}
+
+	// IPs slows down a single client guessing across many accounts
+	IPs = &Guard{
+		Store: defaultStore,
+		Policy: Policy{
+			FreeAttempts:    10,
+			BaseDelay:       time.Second,
+			MaxFailures:     50,
+			LockoutDuration: time.Hour,
+			Window:          time.Hour,
+		},
+	}
+
+	defaultStore = initStore()
+)
+
+func initStore() Store {
+	if config.ENV.LockoutStore == "memory" {
+		return NewMemoryStore()
+	}
+	return NewDatabaseStore()
+}
+
+// Reserve counts an attempt against the key before it is made, and returns how long the caller must wait instead
+// if the key is slowed down or locked out, in which case nothing is counted. Checking and counting in one update
+// keeps parallel attempts from all getting in before any of them fails. An attempt that succeeds should be given
+// back with Release or Reset. locked reports whether this call locked the key out, which happens to the first
+// attempt after MaxFailures.
+func (g *Guard) Reserve(key string, now time.Time) (wait time.Duration, locked bool, err error) {
+	err = g.Store.Update(key, func(attempt Attempt) Attempt {
+		wait, locked = 0, false
+		attempt = g.Policy.current(attempt, now)
+
+		switch {
+		case now.Before(attempt.LockedUntil):
+			wait = attempt.LockedUntil.Sub(now)
+		case attempt.Failures >= g.Policy.MaxFailures:
+			attempt.LockedUntil = now.Add(g.Policy.LockoutDuration)
+			attempt.Failures = 0
+			wait, locked = g.Policy.LockoutDuration, true
+		case now.Before(attempt.LastFailure.Add(g.Policy.delay(attempt.Failures))):
+			wait = attempt.LastFailure.Add(g.Policy.delay(attempt.Failures)).Sub(now)
+		default:
+			attempt.Failures++
+			attempt.LastFailure = now
+		}
+		return attempt
+	})
+	return wait, locked, err
+}
+
+// Release gives back an attempt counted by Reserve that succeeded.
+func (g *Guard) Release(key string) error {
+	return g.Store.Update(key, func(attempt Attempt) Attempt {
+		if attempt.Failures > 0 {
+			attempt.Failures--
+		}
+		return attempt
+	})
+}
+
+// Reset forgets every attempt for the key, usually after a successful one.
+func (g *Guard) Reset(key string) error {
+	return g.Store.Delete(key)
+}
+
+// current returns attempt, or nothing if its failures have fallen out of the window and it is not locked out.
+func (p Policy) current(attempt Attempt, now time.Time) Attempt {
+	if now.Sub(attempt.LastFailure) > p.Window && !now.Before(attempt.LockedUntil) {
+		return Attempt{}
+	}
+	return attempt
+}
+
+// delay returns how long to wait after the given number of failures, doubling up to the lockout duration.
+func (p Policy) delay(failures int) time.Duration {
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(failures-p.FreeAttempts-1))
+	if delay > float64(p.LockoutDuration) {
+		return p.LockoutDuration
+	}
+	return time.Duration(delay)
+}
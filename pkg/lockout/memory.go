@@ -0,0 +1,33 @@
+package lockout
+
+import "sync"
+
+// MemoryStore keeps attempts in process memory. Attempts are lost on restart and are not shared between instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempt
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]Attempt)}
+}
+
+func (s *MemoryStore) Get(key string) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[key], nil
+}
+
+func (s *MemoryStore) Update(key string, fn func(Attempt) Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[key] = fn(s.attempts[key])
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
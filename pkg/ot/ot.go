@@ -0,0 +1,288 @@
+// Package ot implements operational transformation of plain text, as used by collaborative editing sessions.
+// Operations use the JSON format of ot.js: an array whose positive integers retain that many characters, whose
+// negative integers delete that many, and whose strings are inserted. Lengths count Unicode code points.
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// MaxLength is the most characters an operation decoded from JSON may retain, insert or delete in all. Lengths
+// come from clients, and without a bound they could overflow and wrap around into an operation that looks valid.
+const MaxLength = 1 << 30
+
+// Operation turns a text of BaseLen characters into one of TargetLen characters. It is built with Retain,
+// Insert and Delete, which keep it in canonical form: no empty components, no two components of the same kind
+// in a row, and inserts before deletes at the same position.
+type Operation struct {
+	components []component
+	baseLen    int
+	targetLen  int
+}
+
+// component is exactly one of a retain, an insert or a delete.
+type component struct {
+	retain int
+	insert string
+	delete int
+}
+
+func (c component) isRetain() bool { return c.retain > 0 }
+func (c component) isInsert() bool { return c.insert != "" }
+func (c component) isDelete() bool { return c.delete > 0 }
+
+// BaseLen is the length of the texts the operation applies to.
+func (o Operation) BaseLen() int { return o.baseLen }
+
+// TargetLen is the length of the texts the operation produces.
+func (o Operation) TargetLen() int { return o.targetLen }
+
+// IsNoop reports whether the operation leaves texts unchanged.
+func (o Operation) IsNoop() bool {
+	return len(o.components) == 0 || (len(o.components) == 1 && o.components[0].isRetain())
+}
+
+// Retain skips over n characters.
+func (o *Operation) Retain(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLen += n
+	o.targetLen += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].isRetain() {
+		o.components[last].retain += n
+	} else {
+		o.components = append(o.components, component{retain: n})
+	}
+	return o
+}
+
+// Insert inserts s at the current position.
+func (o *Operation) Insert(s string) *Operation {
+	if s == "" {
+		return o
+	}
+	o.targetLen += utf8.RuneCountInString(s)
+	last := len(o.components) - 1
+	switch {
+	case last >= 0 && o.components[last].isInsert():
+		o.components[last].insert += s
+	case last >= 0 && o.components[last].isDelete():
+		// Inserting before or after a delete is the same, and canonical operations insert first
+		if last > 0 && o.components[last-1].isInsert() {
+			o.components[last-1].insert += s
+		} else {
+			o.components = append(o.components, o.components[last])
+			o.components[last] = component{insert: s}
+		}
+	default:
+		o.components = append(o.components, component{insert: s})
+	}
+	return o
+}
+
+// Delete deletes n characters from the current position.
+func (o *Operation) Delete(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLen += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].isDelete() {
+		o.components[last].delete += n
+	} else {
+		o.components = append(o.components, component{delete: n})
+	}
+	return o
+}
+
+// Apply returns the result of the operation on text.
+func (o Operation) Apply(text string) (string, error) {
+	runes := []rune(text)
+	if len(runes) != o.baseLen {
+		return "", fmt.Errorf("operation applies to texts of %d characters, not %d", o.baseLen, len(runes))
+	}
+
+	result := make([]rune, 0, o.targetLen)
+	pos := 0
+	for _, c := range o.components {
+		switch {
+		case c.isRetain():
+			result = append(result, runes[pos:pos+c.retain]...)
+			pos += c.retain
+		case c.isInsert():
+			result = append(result, []rune(c.insert)...)
+		case c.isDelete():
+			pos += c.delete
+		}
+	}
+	return string(result), nil
+}
+
+// Transform takes two operations a and b that apply to the same text, and returns a' and b' such that applying
+// a then b' gives the same text as applying b then a'. When both insert at the same position, the text of a
+// comes first.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.baseLen != b.baseLen {
+		return Operation{}, Operation{}, errors.New("operations apply to texts of different lengths")
+	}
+
+	var aPrime, bPrime Operation
+	as, bs := a.components, b.components
+	var ca, cb *component
+	next := func(cs *[]component) *component {
+		if len(*cs) == 0 {
+			return nil
+		}
+		c := (*cs)[0]
+		*cs = (*cs)[1:]
+		return &c
+	}
+	ca, cb = next(&as), next(&bs)
+
+	for ca != nil || cb != nil {
+		switch {
+		case ca != nil && ca.isInsert():
+			aPrime.Insert(ca.insert)
+			bPrime.Retain(utf8.RuneCountInString(ca.insert))
+			ca = next(&as)
+			continue
+		case cb != nil && cb.isInsert():
+			aPrime.Retain(utf8.RuneCountInString(cb.insert))
+			bPrime.Insert(cb.insert)
+			cb = next(&bs)
+			continue
+		case ca == nil || cb == nil:
+			return Operation{}, Operation{}, errors.New("operations cover texts of different lengths")
+		}
+
+		n := min(ca.retain+ca.delete, cb.retain+cb.delete)
+		switch {
+		case ca.isRetain() && cb.isRetain():
+			aPrime.Retain(n)
+			bPrime.Retain(n)
+		case ca.isDelete() && cb.isRetain():
+			aPrime.Delete(n)
+		case ca.isRetain() && cb.isDelete():
+			bPrime.Delete(n)
+		}
+		// Both deleting the same characters leaves nothing to do
+
+		ca = consume(ca, n, &as, next)
+		cb = consume(cb, n, &bs, next)
+	}
+	return aPrime, bPrime, nil
+}
+
+// consume removes n characters from a retain or delete, moving on to the next component once it is used up.
+func consume(c *component, n int, cs *[]component, next func(*[]component) *component) *component {
+	if c.isRetain() {
+		c.retain -= n
+		if c.retain == 0 {
+			return next(cs)
+		}
+	} else {
+		c.delete -= n
+		if c.delete == 0 {
+			return next(cs)
+		}
+	}
+	return c
+}
+
+// TransformIndex moves a position in a text, such as a cursor, to where it is after the operation.
+func (o Operation) TransformIndex(index int) int {
+	newIndex := index
+	for _, c := range o.components {
+		switch {
+		case c.isRetain():
+			index -= c.retain
+		case c.isInsert():
+			newIndex += utf8.RuneCountInString(c.insert)
+		case c.isDelete():
+			newIndex -= min(index, c.delete)
+			index -= c.delete
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return newIndex
+}
+
+// Diff returns an operation that turns a into b, replacing whatever lies between their common prefix and suffix.
+func Diff(a, b string) Operation {
+	ra, rb := []rune(a), []rune(b)
+
+	prefix := 0
+	for prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ra)-prefix && suffix < len(rb)-prefix && ra[len(ra)-1-suffix] == rb[len(rb)-1-suffix] {
+		suffix++
+	}
+
+	var o Operation
+	o.Retain(prefix)
+	o.Insert(string(rb[prefix : len(rb)-suffix]))
+	o.Delete(len(ra) - prefix - suffix)
+	o.Retain(suffix)
+	return o
+}
+
+func (o Operation) MarshalJSON() ([]byte, error) {
+	items := make([]any, 0, len(o.components))
+	for _, c := range o.components {
+		switch {
+		case c.isRetain():
+			items = append(items, c.retain)
+		case c.isInsert():
+			items = append(items, c.insert)
+		case c.isDelete():
+			items = append(items, -c.delete)
+		}
+	}
+	return json.Marshal(items)
+}
+
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return errors.New("an operation must be an array")
+	}
+
+	*o = Operation{}
+	for _, item := range items {
+		var s string
+		if err := json.Unmarshal(item, &s); err == nil {
+			if s == "" {
+				return errors.New("an operation cannot insert empty strings")
+			}
+			o.Insert(s)
+			if o.targetLen > MaxLength {
+				return fmt.Errorf("an operation cannot cover more than %d characters", MaxLength)
+			}
+			continue
+		}
+
+		var n int
+		if err := json.Unmarshal(item, &n); err != nil || n == 0 {
+			return errors.New("an operation must be made of strings and non-zero integers")
+		}
+		if n > MaxLength || n < -MaxLength {
+			return fmt.Errorf("an operation cannot cover more than %d characters", MaxLength)
+		}
+		if n > 0 {
+			o.Retain(n)
+		} else {
+			o.Delete(-n)
+		}
+		if o.baseLen > MaxLength || o.targetLen > MaxLength {
+			return fmt.Errorf("an operation cannot cover more than %d characters", MaxLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const prefixLength = 5
+
+// BreachedList checks passwords against SHA-1 hashes of leaked passwords, in the k-anonymity format
+// used by the Pwned Passwords range API: hashes are grouped by their first five hex characters,
+// and each line holds the remaining 35 characters followed by a count, e.g. "0018A45C4D1DEF81644B54AB7F969B88D65:10".
+//
+// The list is either a directory of range files named after their prefix ("0018A.txt"), or a single file with
+// one full "HASH:COUNT" per line, sorted by hash as the Pwned Passwords downloads are. Neither is loaded into
+// memory: range files are read on demand, and the single file is binary searched.
+type BreachedList struct {
+	dir  string
+	file string
+}
+
+func LoadBreachedList(path string) (*BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &BreachedList{dir: path}, nil
+	}
+	return &BreachedList{file: path}, nil
+}
+
+// Contains reports whether the password is in the list. Only the hash prefix is used to find candidates.
+func (l *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	if l.file != "" {
+		f, err := os.Open(l.file)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+
+		return searchSorted(f, hash)
+	}
+
+	f, err := os.Open(filepath.Join(l.dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return containsSuffix(f, suffix)
+}
+
+// searchSorted reports whether a file sorted by hash has a line for hash. It binary searches the byte offsets
+// of the file, comparing against the first line that starts at or after each.
+func searchSorted(f *os.File, hash string) (bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// The line for hash, if there is one, always starts in [lo, hi)
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, start, err := lineAt(f, mid, info.Size())
+		if err != nil {
+			return false, err
+		}
+		if start >= info.Size() {
+			hi = mid
+			continue
+		}
+
+		switch cmp := strings.Compare(parseLine(line), hash); {
+		case cmp == 0:
+			return true, nil
+		case cmp < 0:
+			lo = start + int64(len(line))
+		default:
+			hi = mid
+		}
+	}
+	return false, nil
+}
+
+// lineAt returns the first line of f that starts at or after offset, with its newline, and where it starts.
+// start is size if there is none.
+func lineAt(f *os.File, offset, size int64) (line string, start int64, err error) {
+	start = offset
+	if offset > 0 {
+		// Skip the rest of the line that offset falls in, which may end right before it
+		r := bufio.NewReader(io.NewSectionReader(f, offset-1, size-offset+1))
+		skipped, err := r.ReadString('\n')
+		if err == io.EOF {
+			return "", size, nil
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		start = offset - 1 + int64(len(skipped))
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(f, start, size-start))
+	line, err = r.ReadString('\n')
+	if err == io.EOF {
+		err = nil
+	}
+	if line == "" {
+		start = size
+	}
+	return line, start, err
+}
+
+func containsSuffix(r io.Reader, suffix string) (bool, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if parseLine(scanner.Text()) == suffix {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// parseLine returns the upper-cased hash part of a "HASH:COUNT" line.
+func parseLine(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash)
+}
@@ -0,0 +1,105 @@
+package password
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/topboyasante/go-snip/pkg/config"
+)
+
+// bcrypt silently ignores everything past the 72nd byte, so longer passwords would give a false sense of security.
+const MaxBytes = 72
+
+type Policy struct {
+	MinLength int
+	// MinCharClasses is how many of lowercase, uppercase, digits and symbols a password must contain
+	MinCharClasses int
+	// Breached is checked for known leaked passwords when it is not nil
+	Breached *BreachedList
+}
+
+var Default = initPolicy()
+
+func initPolicy() Policy {
+	policy := Policy{
+		MinLength:      config.ENV.PasswordMinLength,
+		MinCharClasses: config.ENV.PasswordMinCharClasses,
+	}
+
+	if config.ENV.BreachedPasswordsPath != "" {
+		list, err := LoadBreachedList(config.ENV.BreachedPasswordsPath)
+		if err != nil {
+			log.Println("failed to load breached password list:", err)
+		} else {
+			policy.Breached = list
+		}
+	}
+
+	return policy
+}
+
+// Check returns every rule the password breaks. The user's username and email are passed as identifiers,
+// which the password may not contain.
+func (p Policy) Check(password string, identifiers ...string) []string {
+	var problems []string
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+
+	if len(password) > MaxBytes {
+		problems = append(problems, fmt.Sprintf("password must be at most %d bytes long", MaxBytes))
+	}
+
+	if charClasses(password) < p.MinCharClasses {
+		problems = append(problems, fmt.Sprintf("password must contain at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinCharClasses))
+	}
+
+	lower := strings.ToLower(password)
+	for _, identifier := range identifiers {
+		// Only check the local part of an email, since the domain is often a common word
+		identifier, _, _ = strings.Cut(strings.ToLower(identifier), "@")
+		if len(identifier) >= 3 && strings.Contains(lower, identifier) {
+			problems = append(problems, "password must not contain your username or email")
+			break
+		}
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			log.Println("failed to check breached password list:", err)
+		} else if breached {
+			problems = append(problems, "password has appeared in a data breach, please choose another")
+		}
+	}
+
+	return problems
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,298 @@
+// Package placeholder fills in the variables of a snippet. Code refers to a variable as {{name}}, with optional
+// spaces inside the braces, and \{{name}} is the literal text "{{name}}". Rendering only substitutes values:
+// there are no expressions, functions or loops, so rendering a snippet never runs anything its author wrote.
+// References to names that are not variables of the snippet are left as they are, so code that uses the same
+// braces for its own templates is unaffected.
+package placeholder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+)
+
+const (
+	// MaxValueLength is the longest value a variable can have, in characters.
+	MaxValueLength = 10000
+	// MaxPatternLength is the longest pattern a variable can have, in characters.
+	MaxPatternLength = 500
+)
+
+// maxCompiledPatterns bounds how many patterns are kept compiled.
+const maxCompiledPatterns = 1000
+
+// ErrTooLarge is returned by Render when the rendered code would be larger than allowed.
+var ErrTooLarge = errors.New("rendered code is too large")
+
+var (
+	namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	reference   = regexp.MustCompile(`\\?\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// compiled holds the patterns of variables once they are compiled, as the same snippets are rendered again and
+// again. It is emptied when full, rather than tracking which patterns are used the most.
+var compiled = struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}{patterns: make(map[string]*regexp.Regexp)}
+
+// Value is the value of a variable. It decodes from a JSON string, number or boolean, so that clients can send
+// {"port": 8080} as well as {"port": "8080"}.
+type Value string
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = Value(s)
+		return nil
+	}
+
+	var scalar any
+	if err := json.Unmarshal(data, &scalar); err != nil {
+		return err
+	}
+	switch scalar.(type) {
+	case float64, bool:
+		*v = Value(data)
+		return nil
+	}
+	return errors.New("must be a string, number or boolean")
+}
+
+// Check reports what is wrong with the declarations of a snippet's variables, with fields named like
+// "variables[0].name".
+func Check(variables []models.SnippetVariable) []apierror.FieldError {
+	var errs []apierror.FieldError
+	seen := make(map[string]bool, len(variables))
+	for i, v := range variables {
+		before := len(errs)
+		field := func(name, message string) {
+			errs = append(errs, apierror.FieldError{Field: fmt.Sprintf("variables[%d].%s", i, name), Message: message})
+		}
+
+		switch {
+		case !namePattern.MatchString(v.Name):
+			field("name", "must start with a letter or underscore, followed by letters, digits or underscores")
+		case seen[v.Name]:
+			field("name", "is already the name of another variable")
+		}
+		seen[v.Name] = true
+
+		switch v.Type {
+		case models.VariableString:
+			if utf8.RuneCountInString(v.Pattern) > MaxPatternLength {
+				field("pattern", fmt.Sprintf("must be at most %d characters long", MaxPatternLength))
+			} else if v.Pattern != "" {
+				if _, err := compile(v.Pattern); err != nil {
+					field("pattern", "is not a valid regular expression")
+				}
+			}
+			if v.Min != nil && *v.Min < 0 {
+				field("min", "cannot be negative for a string")
+			}
+		case models.VariableInt, models.VariableBool, models.VariableEnum:
+			if v.Pattern != "" {
+				field("pattern", "is only allowed for strings")
+			}
+		default:
+			field("type", "must be one of string, int, bool, enum")
+			continue
+		}
+
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			field("max", "cannot be less than min")
+		}
+		if (v.Min != nil || v.Max != nil) && v.Type != models.VariableString && v.Type != models.VariableInt {
+			field("min", "is only allowed for strings and ints")
+		}
+
+		if v.Type == models.VariableEnum {
+			if len(v.Options) == 0 {
+				field("options", "is required for an enum")
+			}
+			for j, option := range v.Options {
+				if slices.Index(v.Options, option) != j {
+					field(fmt.Sprintf("options[%d]", j), "is repeated")
+				}
+			}
+		} else if len(v.Options) > 0 {
+			field("options", "is only allowed for enums")
+		}
+
+		if v.Default != nil && len(errs) == before {
+			if _, message := checkValue(v, *v.Default); message != "" {
+				field("default", message)
+			}
+		}
+	}
+	return errs
+}
+
+// Resolve checks the values given for a snippet's variables, and returns the value of every variable: the given
+// one, in canonical form, or the default. Fields are named like "values.port".
+func Resolve(variables []models.SnippetVariable, values map[string]string) (map[string]string, []apierror.FieldError) {
+	var errs []apierror.FieldError
+	resolved := make(map[string]string, len(variables))
+	for _, v := range variables {
+		value, given := values[v.Name]
+		switch {
+		case given:
+			canonical, message := checkValue(v, value)
+			if message != "" {
+				errs = append(errs, apierror.FieldError{Field: "values." + v.Name, Message: message})
+				continue
+			}
+			resolved[v.Name] = canonical
+		case v.Default != nil:
+			resolved[v.Name] = *v.Default
+			if canonical, message := checkValue(v, *v.Default); message == "" {
+				resolved[v.Name] = canonical
+			}
+		default:
+			errs = append(errs, apierror.FieldError{Field: "values." + v.Name, Message: "is required"})
+		}
+	}
+
+	declared := make(map[string]bool, len(variables))
+	for _, v := range variables {
+		declared[v.Name] = true
+	}
+	var names []string
+	for name := range values {
+		if !declared[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		errs = append(errs, apierror.FieldError{Field: "values." + name, Message: "is not a variable of this snippet"})
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return resolved, nil
+}
+
+// Render replaces the references to variables in code with their values, which should come from Resolve. Code
+// can refer to a variable many times, so the size of the result is worked out first, and ErrTooLarge is returned
+// without rendering anything if it is over maxBytes. A maxBytes of zero is unlimited.
+func Render(code string, values map[string]string, maxBytes int) (string, error) {
+	matches := reference.FindAllStringSubmatchIndex(code, -1)
+
+	size := len(code)
+	for _, m := range matches {
+		size += len(replacement(code[m[0]:m[1]], code[m[2]:m[3]], values)) - (m[1] - m[0])
+		if maxBytes > 0 && size > maxBytes {
+			return "", ErrTooLarge
+		}
+	}
+
+	var b strings.Builder
+	b.Grow(size)
+	last := 0
+	for _, m := range matches {
+		b.WriteString(code[last:m[0]])
+		b.WriteString(replacement(code[m[0]:m[1]], code[m[2]:m[3]], values))
+		last = m[1]
+	}
+	b.WriteString(code[last:])
+	return b.String(), nil
+}
+
+// replacement is what a reference to name, written as match, is rendered as.
+func replacement(match, name string, values map[string]string) string {
+	value, ok := values[name]
+	switch {
+	case !ok:
+		return match
+	case strings.HasPrefix(match, `\`):
+		return match[1:]
+	default:
+		return value
+	}
+}
+
+// checkValue returns the canonical form of a value of v, or a message saying what is wrong with it.
+func checkValue(v models.SnippetVariable, value string) (string, string) {
+	switch v.Type {
+	case models.VariableInt:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		switch {
+		case err != nil:
+			return "", "must be an integer"
+		case v.Min != nil && n < *v.Min:
+			return "", fmt.Sprintf("must be at least %d", *v.Min)
+		case v.Max != nil && n > *v.Max:
+			return "", fmt.Sprintf("must be at most %d", *v.Max)
+		}
+		return strconv.Itoa(n), ""
+
+	case models.VariableBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", "must be true or false"
+		}
+		return strconv.FormatBool(b), ""
+
+	case models.VariableEnum:
+		if !slices.Contains(v.Options, value) {
+			return "", "must be one of " + strings.Join(v.Options, ", ")
+		}
+		return value, ""
+
+	default:
+		length := utf8.RuneCountInString(value)
+		switch {
+		case length > MaxValueLength:
+			return "", fmt.Sprintf("must be at most %d characters long", MaxValueLength)
+		case v.Min != nil && length < *v.Min:
+			return "", fmt.Sprintf("must be at least %d characters long", *v.Min)
+		case v.Max != nil && length > *v.Max:
+			return "", fmt.Sprintf("must be at most %d characters long", *v.Max)
+		case v.Pattern != "" && !matches(v.Pattern, value):
+			return "", "must match " + v.Pattern
+		}
+		return value, ""
+	}
+}
+
+// matches reports whether value matches pattern. Patterns are checked when they are declared, so one that does
+// not compile matches nothing.
+func matches(pattern, value string) bool {
+	re, err := compile(pattern)
+	return err == nil && re.MatchString(value)
+}
+
+// compile returns the compiled pattern, compiling it only the first time.
+func compile(pattern string) (*regexp.Regexp, error) {
+	compiled.Lock()
+	re, ok := compiled.patterns[pattern]
+	compiled.Unlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled.Lock()
+	defer compiled.Unlock()
+	if len(compiled.patterns) >= maxCompiledPatterns {
+		clear(compiled.patterns)
+	}
+	compiled.patterns[pattern] = re
+	return re, nil
+}
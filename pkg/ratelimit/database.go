@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/topboyasante/go-snip/api/v1/models"
+)
+
+// maxUpdateAttempts is how many times DatabaseStore.Update reads a bucket again after losing a race for it.
+const maxUpdateAttempts = 5
+
+// DatabaseStore keeps buckets in the rate_limit_buckets table, so they survive restarts and are shared between
+// instances. Every request writes its bucket, so it costs a round trip or two to the database.
+type DatabaseStore struct {
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewDatabaseStore() *DatabaseStore {
+	return &DatabaseStore{}
+}
+
+func (s *DatabaseStore) Update(key string, now time.Time, ttl time.Duration, fn func(Bucket) Bucket) error {
+	s.sweep(now)
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		row, found, err := models.GetRateLimitBucket(key)
+		if err != nil {
+			return err
+		}
+
+		var b Bucket
+		if found && now.Before(row.ExpiresAt) {
+			b = Bucket{Tokens: row.Tokens, UpdatedAt: row.UpdatedAt}
+		}
+		b = fn(b)
+
+		updated := models.RateLimitBucket{
+			Key:       key,
+			Tokens:    b.Tokens,
+			UpdatedAt: b.UpdatedAt,
+			ExpiresAt: now.Add(ttl),
+			Version:   row.Version + 1,
+		}
+		var saved bool
+		if found {
+			saved, err = updated.SaveIfVersion(row.Version)
+		} else {
+			saved, err = updated.Create()
+		}
+		if err != nil || saved {
+			return err
+		}
+	}
+	return errors.New("too many concurrent requests for the same bucket")
+}
+
+// sweep deletes the buckets that expired, at most once a minute.
+func (s *DatabaseStore) sweep(now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	if err := models.DeleteExpiredRateLimitBuckets(now); err != nil {
+		log.Println("failed to delete expired rate limit buckets:", err)
+	}
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often a MemoryStore forgets the buckets that expired.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in process memory. Buckets are lost on restart and are not shared between instances,
+// so every instance allows the full limit.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	Bucket
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]memoryBucket)}
+}
+
+func (s *MemoryStore) Update(key string, now time.Time, ttl time.Duration, fn func(Bucket) Bucket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.expiresAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok || !now.Before(b.expiresAt) {
+		b = memoryBucket{}
+	}
+	s.buckets[key] = memoryBucket{Bucket: fn(b.Bucket), expiresAt: now.Add(ttl)}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Package ratelimit throttles requests with token buckets. Every key, such as an IP address or a user, has a
+// bucket of Burst tokens that refills evenly over Period; each request takes a token, and requests that find the
+// bucket empty are rejected until it refills.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/topboyasante/go-snip/pkg/config"
+)
+
+// Bucket is the state of the token bucket of a key.
+type Bucket struct {
+	Tokens float64
+	// UpdatedAt is when Tokens was counted. It is zero for a key that has no bucket yet, which starts full.
+	UpdatedAt time.Time
+}
+
+// Store persists buckets. Implementations must be safe for concurrent use.
+type Store interface {
+	// Update replaces the bucket of key with what fn returns for it, atomically, and may call fn more than once
+	// to do so. Buckets that are not updated for ttl may be forgotten, and are then zero.
+	Update(key string, now time.Time, ttl time.Duration, fn func(Bucket) Bucket) error
+}
+
+// Limit is how many requests a key may make.
+type Limit struct {
+	// Burst is how many requests may be made at once, which is the size of the bucket
+	Burst int
+	// Period is how long an empty bucket takes to fill up again
+	Period time.Duration
+}
+
+// ParseLimit parses a limit written as "requests/period", such as "60/1m". An empty spec, "0" or "off" is the
+// zero Limit, which disables limiting.
+func ParseLimit(spec string) (Limit, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "0" || spec == "off" {
+		return Limit{}, nil
+	}
+
+	requests, period, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, errors.New(`limits are written as "requests/period", such as "60/1m"`)
+	}
+	burst, err := strconv.Atoi(requests)
+	if err != nil || burst <= 0 {
+		return Limit{}, fmt.Errorf("%q is not a positive number of requests", requests)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%q is not a positive duration", period)
+	}
+	return Limit{Burst: burst, Period: d}, nil
+}
+
+// IsZero reports whether the limit is disabled.
+func (l Limit) IsZero() bool {
+	return l.Burst == 0
+}
+
+// rate is how many tokens are added per second.
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// Result is the outcome of a request.
+type Result struct {
+	Allowed bool
+	// Remaining is how many more requests may be made right away
+	Remaining int
+	// RetryAfter is how long until the next request may be made, when this one was not allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Limiter applies a limit to keys, keeping their buckets in Store.
+type Limiter struct {
+	// Name tells limiters that share a store apart
+	Name  string
+	Limit Limit
+	Store Store
+}
+
+// DefaultStore is where limiters keep their buckets.
+var DefaultStore = initStore()
+
+func initStore() Store {
+	if config.ENV.RateLimitStore == "database" {
+		return NewDatabaseStore()
+	}
+	return NewMemoryStore()
+}
+
+// Allow takes a token from the bucket of key, if there is one left.
+func (l *Limiter) Allow(key string, now time.Time) (Result, error) {
+	var result Result
+	err := l.Store.Update(l.Name+":"+key, now, l.Limit.Period, func(b Bucket) Bucket {
+		tokens := float64(l.Limit.Burst)
+		if !b.UpdatedAt.IsZero() {
+			elapsed := max(now.Sub(b.UpdatedAt).Seconds(), 0)
+			tokens = min(b.Tokens+elapsed*l.Limit.rate(), tokens)
+		}
+
+		result = Result{Allowed: tokens >= 1}
+		if result.Allowed {
+			tokens--
+		} else {
+			result.RetryAfter = l.wait(1 - tokens)
+		}
+		result.Remaining = int(tokens)
+		result.Reset = l.wait(float64(l.Limit.Burst) - tokens)
+		return Bucket{Tokens: tokens, UpdatedAt: now}
+	})
+	return result, err
+}
+
+// wait returns how long the bucket takes to gain tokens, rounded up to the millisecond.
+func (l *Limiter) wait(tokens float64) time.Duration {
+	ms := math.Ceil(tokens / l.Limit.rate() * 1000)
+	return time.Duration(ms) * time.Millisecond
+}
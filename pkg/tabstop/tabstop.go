@@ -0,0 +1,151 @@
+// Package tabstop reads the editor placeholders in the code of a snippet, so that exporters can write them in
+// the syntax of each editor. The syntax is the common subset of TextMate, VS Code and UltiSnips:
+//
+//	$1, ${1}      tab stop 1
+//	${1:default}  tab stop 1, filled with default text
+//	$0            the final cursor position
+//	\$            a literal dollar sign
+//	\}            a literal closing brace, in default text
+//
+// A dollar sign that does not start a tab stop, as in "$HOME" or "${name}", is literal text.
+package tabstop
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Segment is either literal text or a tab stop.
+type Segment struct {
+	// Stop is the number of a tab stop, or -1 for literal text
+	Stop int
+	// Text is the literal text, or the default text of a tab stop
+	Text string
+}
+
+func (s Segment) IsStop() bool {
+	return s.Stop >= 0
+}
+
+// Parse splits code into literal text and tab stops. It never fails: anything that is not a well-formed tab stop
+// is kept as text.
+func Parse(code string) []Segment {
+	var segments []Segment
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			segments = append(segments, Segment{Stop: -1, Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(code); {
+		if code[i] == '\\' && i+1 < len(code) && code[i+1] == '$' {
+			text.WriteByte('$')
+			i += 2
+			continue
+		}
+		if code[i] != '$' {
+			text.WriteByte(code[i])
+			i++
+			continue
+		}
+
+		stop, n := parseStop(code[i:])
+		if n == 0 {
+			text.WriteByte('$')
+			i++
+			continue
+		}
+		flush()
+		segments = append(segments, stop)
+		i += n
+	}
+	flush()
+	return segments
+}
+
+// parseStop parses the tab stop at the start of s, which starts with a dollar sign, and returns its length,
+// or zero if there is none.
+func parseStop(s string) (Segment, int) {
+	if number, n := digits(s[1:]); n > 0 {
+		return Segment{Stop: number}, 1 + n
+	}
+	if !strings.HasPrefix(s, "${") {
+		return Segment{}, 0
+	}
+
+	number, n := digits(s[2:])
+	if n == 0 {
+		return Segment{}, 0
+	}
+	i := 2 + n
+	if i < len(s) && s[i] == '}' {
+		return Segment{Stop: number}, i + 1
+	}
+	if i >= len(s) || s[i] != ':' {
+		return Segment{}, 0
+	}
+
+	var text strings.Builder
+	for i++; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == '}' || s[i+1] == '$'):
+			i++
+			text.WriteByte(s[i])
+		case s[i] == '}':
+			return Segment{Stop: number, Text: text.String()}, i + 1
+		default:
+			text.WriteByte(s[i])
+		}
+	}
+	// Not closed
+	return Segment{}, 0
+}
+
+func digits(s string) (int, int) {
+	n := 0
+	for n < len(s) && n < 9 && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	number, _ := strconv.Atoi(s[:n])
+	return number, n
+}
+
+// Escape turns text into code without tab stops, escaping the dollar signs that would start one or that
+// follow a backslash.
+func Escape(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '$' && (i > 0 && text[i-1] == '\\' || startsStop(text[i:])) {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+func startsStop(s string) bool {
+	_, n := parseStop(s)
+	return n > 0
+}
+
+// Format writes segments back in the syntax described in the package documentation.
+func Format(segments []Segment) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		switch {
+		case !segment.IsStop():
+			b.WriteString(Escape(segment.Text))
+		case segment.Text == "":
+			b.WriteString("${" + strconv.Itoa(segment.Stop) + "}")
+		default:
+			text := strings.NewReplacer(`$`, `\$`, `}`, `\}`).Replace(segment.Text)
+			b.WriteString("${" + strconv.Itoa(segment.Stop) + ":" + text + "}")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/topboyasante/go-snip/pkg/apierror"
+)
+
+// RegisterBindingRules teaches gin's validator the rules used in the binding tags of the request types:
+//
+//	notblank  a string with something other than whitespace
+//	email     an email address matching EmailRX, instead of the validator's looser check
+//	httpurl   an absolute http or https URL, see IsURL, or an empty string so that optional URLs can be cleared
+//	nospace   a string without whitespace
+//
+// It also makes field errors use the json (or form) name of a field. It must be called before any request is bound.
+func RegisterBindingRules() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin is not using go-playground/validator")
+	}
+
+	v.RegisterTagNameFunc(fieldName)
+
+	rules := map[string]func(string) bool{
+		"notblank": NotBlank,
+		"email":    func(value string) bool { return Matches(value, EmailRX) },
+		"httpurl":  func(value string) bool { return value == "" || IsURL(value) },
+		"nospace":  func(value string) bool { return !strings.ContainsFunc(value, unicode.IsSpace) },
+	}
+	for tag, rule := range rules {
+		err := v.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return rule(fl.Field().String())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// FieldErrors describes every field that failed validation, or reports false if err is not a validation error.
+func FieldErrors(err error) ([]apierror.FieldError, bool) {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil, false
+	}
+
+	fields := make([]apierror.FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, apierror.FieldError{
+			Field:   fieldPath(fe),
+			Message: message(fe),
+		})
+	}
+	return fields, true
+}
+
+// fieldPath drops the name of the request struct from the namespace, e.g. "tags[2]" for "NewSnippetRequest.tags[2]".
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless", "notblank":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "httpurl", "url":
+		return "must be an absolute http or https URL"
+	case "nospace":
+		return "cannot contain spaces"
+	case "ip":
+		return "must be a valid IP address"
+	case "bcp47_language_tag":
+		return "must be a language tag, such as en or pt-BR"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit(fe.Kind()))
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit(fe.Kind()))
+	case "len":
+		return fmt.Sprintf("must be exactly %s%s", fe.Param(), unit(fe.Kind()))
+	case "gt":
+		if _, ok := fe.Value().(time.Time); ok && fe.Param() == "" {
+			return "must be in the future"
+		}
+		return "must be greater than " + fe.Param()
+	default:
+		return "is invalid"
+	}
+}
+
+// unit names what min, max and len count: characters for strings, items for slices and nothing for numbers.
+func unit(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Map:
+		return " items"
+	default:
+		return ""
+	}
+}
@@ -1,6 +1,7 @@
 package validators
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
 	"unicode/utf8"
@@ -30,4 +31,13 @@ func Matches(value string, rx *regexp.Regexp) bool {
 
 func NotZero(value int) bool {
 	return value > 1000
-}
\ No newline at end of file
+}
+
+// IsURL() returns true if a value is an absolute http or https URL.
+func IsURL(value string) bool {
+	u, err := url.ParseRequestURI(value)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
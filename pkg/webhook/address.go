@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for webhook URLs that reach the server itself or its private network rather
+// than the internet, so that webhooks cannot be used to probe or read internal services.
+var ErrForbiddenAddress = errors.New("webhook URLs must point to a public address")
+
+// nonPublic lists the ranges that are not reachable from the internet, besides those netip.Addr tells apart.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// isPublic tells whether ip is a unicast address on the internet.
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublic {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL returns ErrForbiddenAddress if the host of rawURL is, or resolves to, an address that is not public.
+// The addresses a host resolves to can change, so deliveries check them again when they connect.
+func CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !isPublic(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("could not resolve %s", host)
+	}
+	for _, ip := range ips {
+		if !isPublic(ip) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// dialPublic is a net.Dialer Control function that refuses connections to addresses that are not public. It runs
+// after the host is resolved, for every connection, so it also covers DNS rebinding.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublic(addrPort.Addr()) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// newTransport returns a transport that only connects to public addresses, and ignores proxy settings, which
+// would have it connect to the proxy instead.
+func newTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublic}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
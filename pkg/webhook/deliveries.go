@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/pkg/config"
+)
+
+// Worker sends the deliveries queued by Dispatch. Like email.Worker, several instances may share the table, as
+// every delivery is claimed before it is sent.
+type Worker struct {
+	Client *http.Client
+	// Interval is how often deliveries that are due are looked for, besides when Wake is called
+	Interval time.Duration
+	// BatchSize is how many deliveries are claimed at a time
+	BatchSize int
+	// Lease is how long a claimed delivery is left alone by other workers. The deliveries of a batch are sent one
+	// after another, so it must be longer than sending a whole batch takes.
+	Lease time.Duration
+	// MaxAttempts is how many failures it takes to give up on a delivery
+	MaxAttempts int
+	// DisableAfter is how many failed attempts in a row, across deliveries, disable a webhook
+	DisableAfter int
+	// BaseDelay is the wait after the first failure; it doubles with every further failure, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	wake chan struct{}
+}
+
+// deliveryBatchSize is how many deliveries Deliveries claims at a time.
+const deliveryBatchSize = 20
+
+// Deliveries sends the deliveries queued by Dispatch and Redeliver, once it is started with Run.
+var Deliveries = &Worker{
+	Client: &http.Client{
+		Timeout:   config.ENV.WebhookTimeout,
+		Transport: newTransport(),
+		// Redirects count as failures, so that a webhook cannot send a signed delivery elsewhere
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	},
+	Interval:     30 * time.Second,
+	BatchSize:    deliveryBatchSize,
+	Lease:        deliveryBatchSize*config.ENV.WebhookTimeout + time.Minute,
+	MaxAttempts:  config.ENV.WebhookMaxAttempts,
+	DisableAfter: config.ENV.WebhookDisableAfter,
+	BaseDelay:    30 * time.Second,
+	MaxDelay:     6 * time.Hour,
+	wake:         make(chan struct{}, 1),
+}
+
+// Wake makes a running worker look for due deliveries now rather than at its next interval.
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends deliveries until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			sent, err := w.Process(time.Now())
+			if err != nil {
+				log.Println("failed to process webhook deliveries:", err)
+			}
+			if err != nil || sent < w.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// Process claims a batch of due deliveries and tries to send each one, returning how many were claimed.
+func (w *Worker) Process(now time.Time) (int, error) {
+	deliveries, err := models.ClaimDueWebhookDeliveries(now, w.BatchSize, w.Lease)
+	for i := range deliveries {
+		w.send(&deliveries[i])
+	}
+	return len(deliveries), err
+}
+
+// send delivers a claimed delivery, and records the outcome on it and on its webhook.
+func (w *Worker) send(delivery *models.WebhookDelivery) {
+	webhook, found, err := models.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		// The delivery is sent again once its lease runs out
+		log.Printf("failed to load webhook %s: %v", delivery.WebhookID, err)
+		return
+	}
+	if !found || !webhook.Active {
+		now := time.Now()
+		delivery.FailedAt = &now
+		delivery.LastError = "webhook is disabled"
+		w.save(delivery)
+		return
+	}
+
+	start := time.Now()
+	delivery.StatusCode, err = w.post(webhook, delivery)
+	now := time.Now()
+	delivery.DurationMS = now.Sub(start).Milliseconds()
+
+	if err == nil {
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	} else {
+		delivery.Attempts++
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(w.backoff(delivery.Attempts))
+		if delivery.Attempts >= w.MaxAttempts {
+			delivery.FailedAt = &now
+		}
+	}
+	w.save(delivery)
+
+	disabled, err := models.RecordWebhookResult(webhook.ID, delivery.DeliveredAt != nil, w.DisableAfter, now)
+	if err != nil {
+		log.Printf("failed to update webhook %s: %v", webhook.ID, err)
+	}
+	if disabled {
+		log.Printf("disabled webhook %s after %d failed attempts in a row", webhook.ID, w.DisableAfter)
+	}
+}
+
+// post sends a delivery, returning the status of the response. Responses other than 2xx are errors. The body
+// of the response is not kept, so that the delivery log cannot be used to read what a URL returns.
+func (w *Worker) post(webhook models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-snip-webhooks")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+	res, err := w.Client.Do(req)
+	if err != nil {
+		// Strip the method and URL that the client adds, as the log already belongs to the webhook
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		// Leave out the addresses of the connection, which the owner of the webhook is not to learn
+		var opErr *net.OpError
+		if errors.Is(err, ErrForbiddenAddress) {
+			err = ErrForbiddenAddress
+		} else if errors.As(err, &opErr) {
+			err = opErr.Err
+		}
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Read some of the body so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+func (w *Worker) save(delivery *models.WebhookDelivery) {
+	if err := delivery.Save(); err != nil {
+		log.Printf("failed to update webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// backoff is the wait before the next attempt after the given number of failures.
+func (w *Worker) backoff(failures int) time.Duration {
+	delay := w.BaseDelay
+	for i := 1; i < failures && delay < w.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, w.MaxDelay)
+}
@@ -0,0 +1,119 @@
+// Package webhook sends events on the snippets of a user to the URLs they subscribed. Controllers queue events
+// with Dispatch, one delivery per subscribed webhook, and the Deliveries worker POSTs them in the background,
+// retrying failures with backoff and disabling webhooks that keep failing.
+//
+// Every delivery is a JSON Event signed with the secret of its webhook: the X-Snip-Signature header holds
+// "sha256=" and the hex HMAC-SHA256 of the X-Snip-Timestamp header, a dot, and the body. Receivers should compute
+// the same HMAC, compare it in constant time, and reject old timestamps to guard against replays.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topboyasante/go-snip/api/v1/models"
+	"github.com/topboyasante/go-snip/pkg/events"
+)
+
+// Events lists the events webhooks can subscribe to, in the order they are documented.
+var Events = []string{events.SnippetCreated, events.SnippetUpdated, events.SnippetDeleted}
+
+// Headers of a delivery
+const (
+	EventHeader     = "X-Snip-Event"
+	DeliveryHeader  = "X-Snip-Delivery"
+	TimestampHeader = "X-Snip-Timestamp"
+	SignatureHeader = "X-Snip-Signature"
+)
+
+// Event is the body of a delivery. A redelivery sends the same event, ID included, so that receivers can tell
+// it apart from a new one.
+type Event struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// NewSecret returns a random secret to sign the deliveries of a new webhook with.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Snip-Signature header of a delivery sent at timestamp, in Unix seconds.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch queues an event for every active webhook of a user that subscribes to it.
+func Dispatch(userID uuid.UUID, eventType string, data any) error {
+	webhooks, err := models.ListActiveWebhooks(userID)
+	if err != nil {
+		return fmt.Errorf("listing webhooks for %s event: %w", eventType, err)
+	}
+	webhooks = slices.DeleteFunc(webhooks, func(w models.Webhook) bool {
+		return !slices.Contains(w.Events, eventType)
+	})
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding %s event: %w", eventType, err)
+	}
+
+	for _, w := range webhooks {
+		delivery := models.WebhookDelivery{
+			ID:            uuid.New(),
+			WebhookID:     w.ID,
+			Event:         eventType,
+			Payload:       string(payload),
+			NextAttemptAt: time.Now(),
+		}
+		if err := delivery.Create(); err != nil {
+			return fmt.Errorf("queueing %s event: %w", eventType, err)
+		}
+	}
+
+	Deliveries.Wake()
+	return nil
+}
+
+// Redeliver queues a delivery to be sent again, as a new delivery with the same payload.
+func Redeliver(original models.WebhookDelivery) (models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		ID:            uuid.New(),
+		WebhookID:     original.WebhookID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		RedeliveryOf:  &original.ID,
+		NextAttemptAt: time.Now(),
+	}
+	if err := delivery.Create(); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	Deliveries.Wake()
+	return delivery, nil
+}